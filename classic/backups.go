@@ -0,0 +1,295 @@
+package classic
+
+import (
+	"classicserver/classic/packets"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const BACKUPS_DIRECTORY = "backups"
+
+const BACKUP_TIME_FORMAT = "20060102-150405"
+
+// Backups hold the blocks and spawn of the world; its settings in world.txt are kept on restore
+type Backup struct {
+	Name string
+	Time time.Time
+	Path string
+
+	sequence int // Counts backups taken within the same second, which get a suffix after the first
+}
+
+// Held while choosing a backup's name and writing it, so backups taken together don't share a name
+var backupLock sync.Mutex
+
+// Reads the time, and the suffix of later backups in the same second, from a backup's name
+func parseBackupName(name string) (time.Time, int, bool) {
+	sequence := 1
+	if i := strings.LastIndex(name, "-"); i > len(BACKUP_TIME_FORMAT)-1 {
+		number, err := strconv.Atoi(name[i+1:])
+		if err != nil || number < 2 {
+			return time.Time{}, 0, false
+		}
+		name, sequence = name[:i], number
+	}
+
+	backupTime, err := time.ParseInLocation(BACKUP_TIME_FORMAT, name, time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+
+	return backupTime, sequence, true
+}
+
+// Lists the world's backups, newest first
+func ListBackups(world *World) ([]Backup, error) {
+	directory := world.Path(BACKUPS_DIRECTORY)
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Backup{}, nil
+		}
+		return nil, err
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(WORLD_FILENAME))
+		if entry.IsDir() || name == entry.Name() {
+			continue
+		}
+
+		backupTime, sequence, ok := parseBackupName(name)
+		if !ok {
+			continue
+		}
+
+		backups = append(backups, Backup{
+			Name:     name,
+			Time:     backupTime,
+			Path:     filepath.Join(directory, entry.Name()),
+			sequence: sequence,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].sequence > backups[j].sequence
+		}
+		return backups[i].Time.After(backups[j].Time)
+	})
+
+	return backups, nil
+}
+
+// Finds a backup by its name, or by its number in the /backups list
+func FindBackup(world *World, name string) (*Backup, error) {
+	backups, err := ListBackups(world)
+	if err != nil {
+		return nil, err
+	}
+
+	if number, err := strconv.Atoi(name); err == nil && number >= 1 && number <= len(backups) {
+		return &backups[number-1], nil
+	}
+
+	for i := range backups {
+		if backups[i].Name == name {
+			return &backups[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown backup \"%s\"", name)
+}
+
+// Writes a backup of the world, named after the current time
+func CreateBackup(world *World) (*Backup, error) {
+	data, err := encodeWorld(world)
+	if err != nil {
+		return nil, err
+	}
+
+	backupLock.Lock()
+	defer backupLock.Unlock()
+
+	now := time.Now()
+	backup := Backup{
+		Name:     now.Format(BACKUP_TIME_FORMAT),
+		Time:     now,
+		sequence: 1,
+	}
+	for {
+		backup.Path = filepath.Join(world.Path(BACKUPS_DIRECTORY), backup.Name+filepath.Ext(WORLD_FILENAME))
+		if _, err := os.Stat(backup.Path); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		backup.sequence++
+		backup.Name = fmt.Sprintf("%s-%d", now.Format(BACKUP_TIME_FORMAT), backup.sequence)
+	}
+
+	if err := writeFileAtomic(backup.Path, data); err != nil {
+		return nil, err
+	}
+
+	return &backup, nil
+}
+
+// Backs up a snapshot of the live world before a restore replaces it, unless the world was deleted
+func backupBeforeRestore(live *World, snapshot *World) error {
+	live.saveLock.Lock()
+	defer live.saveLock.Unlock()
+
+	if live.savedSnapshot == math.MaxUint64 {
+		return ErrSaveSkipped
+	}
+
+	snapshot.Name = live.Name // In case the world was renamed meanwhile
+	_, err := CreateBackup(snapshot)
+	return err
+}
+
+// Loads the backup as a world, with the name and settings of the given world
+func LoadBackup(backup *Backup, settingsFrom *World) (*World, error) {
+	contents, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	world := &World{
+		Name:          settingsFrom.Name,
+		Hacks:         settingsFrom.Hacks,
+		Env:           settingsFrom.Env,
+		Palette:       settingsFrom.Palette,
+		ExtraMetadata: settingsFrom.ExtraMetadata,
+	}
+	if _, err := decodeWorld(world, contents); err != nil {
+		return nil, fmt.Errorf("Unable to load backup %s: %w", backup.Name, err)
+	}
+
+	return world, nil
+}
+
+// Backups to delete, keeping the newest backup, the newest of each hour for keepHourly hours
+// and the newest of each day for keepDaily days
+func expiredBackups(backups []Backup, now time.Time, keepHourly int, keepDaily int) []Backup {
+	expired := []Backup{}
+	buckets := make(map[string]bool)
+
+	for i, backup := range backups {
+		if i == 0 {
+			continue
+		}
+
+		age := now.Sub(backup.Time)
+		bucket := ""
+		if age < time.Duration(keepHourly)*time.Hour {
+			bucket = "hour " + backup.Time.Format("2006010215")
+		} else if age < time.Duration(keepDaily)*24*time.Hour {
+			bucket = "day " + backup.Time.Format("20060102")
+		}
+
+		if bucket == "" || buckets[bucket] {
+			expired = append(expired, backup)
+			continue
+		}
+		buckets[bucket] = true
+	}
+
+	return expired
+}
+
+func PruneBackups(world *World, keepHourly int, keepDaily int) error {
+	backups, err := ListBackups(world)
+	if err != nil {
+		return err
+	}
+
+	for _, backup := range expiredBackups(backups, time.Now(), keepHourly, keepDaily) {
+		if err := os.Remove(backup.Path); err != nil {
+			return err
+		}
+		log.Printf("-- Removed expired backup %s of %s\n", backup.Name, world.Name)
+	}
+
+	return nil
+}
+
+// Whether a backup of the world should be taken with this save, every backupMinutes minutes
+func (world *World) backupDue(backupMinutes int) bool {
+	if backupMinutes <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	if now.Sub(world.lastBackup) < time.Duration(backupMinutes)*time.Minute {
+		return false
+	}
+
+	world.lastBackup = now
+	return true
+}
+
+// Backs up a world that has been saved, then removes expired backups
+func (server *ClassicServer) backupWorld(world *World) {
+	backup, err := CreateBackup(world)
+	if err != nil {
+		log.Println(err)
+		log.Printf("-- Backup of %s failed\n", world.Name)
+		return
+	}
+	log.Printf("-- %s backed up as %s\n", world.Name, backup.Name)
+
+	if err := PruneBackups(world, server.Settings.BackupKeepHourly, server.Settings.BackupKeepDaily); err != nil {
+		log.Println(err)
+	}
+}
+
+// Shows the player a read-only copy of their world, such as a backup, keeping their position
+func (server *ClassicServer) ViewWorld(player *Player, world *World) error {
+	world.ReadOnly = true
+	return server.moveToWorld(player, world, player.X, player.Y, player.Z, player.Yaw, player.Pitch)
+}
+
+// Returns a player viewing a read-only copy to the live world, keeping their position, once it has loaded
+func (server *ClassicServer) StopViewing(player *Player) {
+	if !player.World.ReadOnly {
+		return
+	}
+
+	server.GetWorld(player.World.Name, func(world *World, err error) {
+		if err != nil {
+			player.SendMessage("%s%s", COLOR_RED, err.Error())
+			log.Println(err)
+			return
+		}
+
+		// The player may have left, or moved on, while the world loaded
+		if player.Conn == nil || !player.World.ReadOnly {
+			return
+		}
+
+		if err := server.moveToWorld(player, world, player.X, player.Y, player.Z, player.Yaw, player.Pitch); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+// Undoes a block change the client made locally in a read-only world
+func (player *Player) RevertBlock(x int16, y int16, z int16) {
+	world := player.World
+	if world.ValidBlock(x, y, z) {
+		player.Write(packets.NewDownstreamSetBlock(x, y, z, world.GetBlock(x, y, z)))
+	}
+	player.SendMessage("%sThis world is read-only; use /restore back to return", COLOR_RED)
+}
@@ -0,0 +1,104 @@
+package classic
+
+import (
+	. "classicserver/classic/constants"
+	"classicserver/classic/packets"
+)
+
+// Below this many changes, individual Set Block packets take no more bytes than a Bulk Block Update
+const BULK_BLOCK_UPDATE_MIN = 160
+
+type BlockChange struct {
+	X     int16
+	Y     int16
+	Z     int16
+	Block Block
+}
+
+// Queues a block change to be sent to players in the world on the next flush, replacing any queued change at the same position
+func (world *World) queueBlockChange(x int16, y int16, z int16, block Block) {
+	if world.pendingBlockIndices == nil {
+		world.pendingBlockIndices = make(map[int32]int)
+	}
+
+	change := BlockChange{X: x, Y: y, Z: z, Block: block}
+	index := world.BlockIndex(x, y, z)
+	if i, ok := world.pendingBlockIndices[index]; ok {
+		world.pendingBlocks[i] = change
+		return
+	}
+
+	world.pendingBlockIndices[index] = len(world.pendingBlocks)
+	world.pendingBlocks = append(world.pendingBlocks, change)
+}
+
+// Sends the block changes queued in every world during this tick
+func (server *ClassicServer) FlushBlockChanges() {
+	for _, world := range server.Worlds {
+		server.flushWorldBlockChanges(world)
+	}
+}
+
+// Sends the world's queued block changes to its players, and keeps its cached level data up to date
+func (server *ClassicServer) flushWorldBlockChanges(world *World) {
+	world.updateLevelCache()
+
+	if len(world.pendingBlocks) == 0 {
+		return
+	}
+
+	changes := world.pendingBlocks
+	world.pendingBlocks = nil
+	world.pendingBlockIndices = nil
+
+	world.sendBlockChanges(server.PlayersIn(world), changes)
+}
+
+// Sends block changes to the players, batching them for clients supporting BulkBlockUpdate
+func (world *World) sendBlockChanges(players []*Player, changes []BlockChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	setBlockPackets := make([]packets.DownstreamSetBlock, len(changes))
+	for i, change := range changes {
+		setBlockPackets[i] = packets.NewDownstreamSetBlock(change.X, change.Y, change.Z, change.Block)
+	}
+
+	bulkPackets := []packets.DownstreamBulkBlockUpdate{}
+	var singlePackets []packets.DownstreamSetBlock
+	for i := 0; i < len(changes); i += packets.BULK_BLOCK_UPDATE_SIZE {
+		end := i + packets.BULK_BLOCK_UPDATE_SIZE
+		if end > len(changes) {
+			end = len(changes)
+		}
+
+		if end-i < BULK_BLOCK_UPDATE_MIN {
+			singlePackets = setBlockPackets[i:end]
+			break
+		}
+
+		indices := make([]int32, 0, end-i)
+		blocks := make([]Block, 0, end-i)
+		for _, change := range changes[i:end] {
+			indices = append(indices, world.BlockIndex(change.X, change.Y, change.Z))
+			blocks = append(blocks, change.Block)
+		}
+		bulkPackets = append(bulkPackets, packets.NewDownstreamBulkBlockUpdate(indices, blocks))
+	}
+
+	for _, player := range players {
+		if player.HasExtension(packets.EXT_BULK_BLOCK_UPDATE) {
+			for _, packet := range bulkPackets {
+				player.Write(packet)
+			}
+			for _, packet := range singlePackets {
+				player.Write(packet)
+			}
+		} else {
+			for _, packet := range setBlockPackets {
+				player.Write(packet)
+			}
+		}
+	}
+}
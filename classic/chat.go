@@ -2,9 +2,13 @@ package classic
 
 import (
 	. "classicserver/classic/constants"
+	"classicserver/classic/packets"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/exp/slices"
 )
@@ -30,6 +34,10 @@ const (
 )
 
 func (server *ClassicServer) HandleChat(player *Player, message string) {
+	if len(message) == 0 {
+		return
+	}
+
 	if message[0] == '/' {
 		message := message[1:]
 		parts := strings.Split(message, " ")
@@ -37,6 +45,10 @@ func (server *ClassicServer) HandleChat(player *Player, message string) {
 		args := parts[1:]
 		server.HandleCommand(player, command, args)
 	} else {
+		if !player.CanUseChatColors() {
+			message = server.StripColors(message)
+		}
+
 		formatted := fmt.Sprintf("%s%s%s: %s", COLOR_WHITE, player.Username, COLOR_GRAY, message)
 		server.BroadcastMessage(player.Id, formatted)
 	}
@@ -52,6 +64,24 @@ func (server *ClassicServer) HandleCommand(player *Player, command string, args
 	case "about":
 		handleAbout(server, player, args)
 
+	case "ping":
+		handlePing(server, player, args)
+
+	case "list":
+		handleList(server, player, args)
+
+	case "goto":
+		handleGoto(server, player, args)
+
+	case "worlds":
+		handleWorlds(server, player, args)
+
+	case "blockinfo":
+		handleBlockInfo(server, player, args)
+
+	case "checkpoint":
+		handleCheckpoint(server, player, args)
+
 	case "kick":
 		if player.Mode != MODE_OP {
 			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
@@ -101,6 +131,97 @@ func (server *ClassicServer) HandleCommand(player *Player, command string, args
 		}
 		handleSetSpawn(server, player, args)
 
+	case "model":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleModel(server, player, args)
+
+	case "hacks":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleHacks(server, player, args)
+
+	case "launch":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleLaunch(server, player, args)
+
+	case "effect":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleEffect(server, player, args)
+
+	case "weather":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleWeather(server, player, args)
+
+	case "palette":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handlePalette(server, player, args)
+
+	case "importworld":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleImportWorld(server, player, args)
+
+	case "exportworld":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleExportWorld(server, player, args)
+
+	case "backups":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleBackups(server, player, args)
+
+	case "restore":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleRestore(server, player, args)
+
+	case "newworld":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleNewWorld(server, player, args)
+
+	case "deleteworld":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleDeleteWorld(server, player, args)
+
+	case "renameworld":
+		if player.Mode != MODE_OP {
+			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
+			return
+		}
+		handleRenameWorld(server, player, args)
+
 	case "saveworld":
 		if player.Mode != MODE_OP {
 			player.SendMessage("%sThis command is only available to operators", COLOR_DARK_RED)
@@ -119,6 +240,12 @@ func handleHelp(server *ClassicServer, player *Player, args []string) {
 	player.SendMessage("%sAvailable Commands:", COLOR_TEAL)
 	player.SendMessage("%s - /help - Show available commands", COLOR_DARK_TEAL)
 	player.SendMessage("%s - /about - Display server info", COLOR_DARK_TEAL)
+	player.SendMessage("%s - /ping [username] - Show measured latency", COLOR_DARK_TEAL)
+	player.SendMessage("%s - /list - List online players", COLOR_DARK_TEAL)
+	player.SendMessage("%s - /goto <world> - Go to another world", COLOR_DARK_TEAL)
+	player.SendMessage("%s - /worlds - List worlds", COLOR_DARK_TEAL)
+	player.SendMessage("%s - /blockinfo - Click a block to identify it", COLOR_DARK_TEAL)
+	player.SendMessage("%s - /checkpoint - Respawn at your current position", COLOR_DARK_TEAL)
 	if player.Mode == MODE_OP {
 		player.SendMessage("%sOperator Commands:", COLOR_TEAL)
 		player.SendMessage("%s - /kick <username> [reason] - Disconnect user", COLOR_DARK_TEAL)
@@ -127,8 +254,21 @@ func handleHelp(server *ClassicServer, player *Player, args []string) {
 		player.SendMessage("%s - /tp <playerfrom> <playerto> - Teleport a player to another", COLOR_DARK_TEAL)
 		player.SendMessage("%s - /op <username> - Make user an operator", COLOR_DARK_TEAL)
 		player.SendMessage("%s - /deop <username> - Remove operator from user", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /model <username> <model> - Change the model a player appears as", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /hacks [hack] [value] - Show or restrict the world's hacks", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /launch <username> <x> <y> <z> - Set a player's velocity", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /effect <effect> [x y z] - Spawn a particle effect", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /weather [sun|rain|snow|cycle] - Override the world's weather", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /palette [blocks...|reset] - Show or set the world's inventory order", COLOR_DARK_TEAL)
 		player.SendMessage("%s - /setspawn - Set server world spawn, saving the world", COLOR_DARK_TEAL)
 		player.SendMessage("%s - /saveworld - Save the world", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /backups - List world backups", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /restore <backup> [view] - Restore a backup, or view it read-only", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /newworld <name> <x> <y> <z> <generator> [seed] - Create a world", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /deleteworld <name> - Delete a world and its backups", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /renameworld <name> <new name> - Rename a world", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /importworld <file> - Replace the world with a .cw, .lvl, .dat or .mine file", COLOR_DARK_TEAL)
+		player.SendMessage("%s - /exportworld <file> - Export the world as a .cw or .lvl file", COLOR_DARK_TEAL)
 	}
 }
 
@@ -137,6 +277,231 @@ func handleAbout(server *ClassicServer, player *Player, args []string) {
 	player.SendMessage("%s%s", COLOR_DARK_TEAL, server.Settings.MOTD)
 }
 
+func handlePing(server *ClassicServer, player *Player, args []string) {
+	target := player
+	if len(args) > 0 {
+		target = server.GetPlayerFromName(args[0])
+		if target == nil {
+			player.SendMessage("%sCould not find player \"%s\"", COLOR_RED, args[0])
+			return
+		}
+	}
+
+	if !target.HasExtension(packets.EXT_TWO_WAY_PING) {
+		player.SendMessage("%s%s's client does not support ping measurement", COLOR_RED, target.Username)
+		return
+	}
+
+	player.SendMessage("%s%s's ping: %s", COLOR_TEAL, target.Username, target.PingString())
+}
+
+func handleList(server *ClassicServer, player *Player, args []string) {
+	player.SendMessage("%sOnline Players (%d):", COLOR_TEAL, len(server.Players))
+	for _, other := range server.Players {
+		player.SendMessage("%s - %s in %s (%s)", COLOR_DARK_TEAL, other.Username, other.World.Name, other.PingString())
+	}
+}
+
+func handleGoto(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 1 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/goto <world>", COLOR_RED)
+		return
+	}
+
+	server.GetWorld(args[0], func(world *World, err error) {
+		if err != nil {
+			player.SendMessage("%s%s", COLOR_RED, err.Error())
+			log.Println(err)
+			return
+		}
+
+		// The player may have left while the world loaded
+		if player.Conn == nil {
+			return
+		}
+
+		if world == player.World {
+			player.SendMessage("%sYou are already in %s", COLOR_RED, world.Name)
+			return
+		}
+
+		if err := server.ChangeWorld(player, world); err != nil {
+			player.SendMessage("%s%s", COLOR_RED, err.Error())
+			return
+		}
+
+		player.SendMessage("%sWelcome to %s", COLOR_GREEN, world.Name)
+		log.Printf("%s has gone to world %s\n", player.Username, world.Name)
+	})
+}
+
+func handleWorlds(server *ClassicServer, player *Player, args []string) {
+	names, err := ListWorlds()
+	if err != nil {
+		player.SendMessage("%sUnable to list worlds", COLOR_RED)
+		log.Println(err)
+		return
+	}
+
+	player.SendMessage("%sWorlds (%d):", COLOR_TEAL, len(names))
+	for _, name := range names {
+		world, loaded := server.Worlds[name]
+		if !loaded {
+			player.SendMessage("%s - %s", COLOR_DARK_TEAL, name)
+		} else if world == player.World {
+			player.SendMessage("%s - %s (%d players, you are here)", COLOR_DARK_TEAL, name, len(server.PlayersIn(world)))
+		} else {
+			player.SendMessage("%s - %s (%d players)", COLOR_DARK_TEAL, name, len(server.PlayersIn(world)))
+		}
+	}
+}
+
+func handleNewWorld(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 5 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/newworld <name> <x> <y> <z> <generator> [seed]", COLOR_RED)
+		player.SendMessage("%sGenerators: %s", COLOR_RED, strings.Join(WorldGeneratorNames(), ", "))
+		return
+	}
+
+	var size [3]int16
+	for i := range size {
+		parsed, err := strconv.ParseInt(args[i+1], 10, 16)
+		if err != nil {
+			player.SendMessage("%sInvalid size \"%s\"", COLOR_RED, args[i+1])
+			return
+		}
+		size[i] = int16(parsed)
+	}
+
+	generator, err := GetWorldGenerator(args[4])
+	if err != nil {
+		player.SendMessage("%s%s", COLOR_RED, err.Error())
+		return
+	}
+
+	seed := time.Now().UnixNano()
+	if len(args) > 5 {
+		seed, err = strconv.ParseInt(args[5], 10, 64)
+		if err != nil {
+			player.SendMessage("%sInvalid seed \"%s\"", COLOR_RED, args[5])
+			return
+		}
+	}
+
+	err = server.CreateWorld(args[0], size[0], size[1], size[2], generator, seed, func(world *World, err error) {
+		if err != nil {
+			player.SendMessage("%sUnable to create world %s", COLOR_RED, args[0])
+			log.Println(err)
+			return
+		}
+
+		player.SendMessage("%sCreated world %s; use /goto %s to visit it", COLOR_GREEN, world.Name, world.Name)
+		log.Printf("%s has created world %s (%dx%dx%d, %s, seed %d)\n", player.Username, world.Name, world.SizeX, world.SizeY, world.SizeZ, strings.ToLower(args[4]), seed)
+	})
+	if err != nil {
+		player.SendMessage("%s%s", COLOR_RED, err.Error())
+		log.Println(err)
+		return
+	}
+
+	player.SendMessage("%sGenerating world %s...", COLOR_TEAL, args[0])
+}
+
+func handleDeleteWorld(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 1 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/deleteworld <name>", COLOR_RED)
+		return
+	}
+
+	err := server.DeleteWorld(args[0], func(err error) {
+		if err != nil {
+			player.SendMessage("%sUnable to delete world %s", COLOR_RED, args[0])
+			log.Println(err)
+			return
+		}
+
+		player.SendMessage("%sDeleted world %s", COLOR_GREEN, args[0])
+		log.Printf("%s has deleted world %s\n", player.Username, args[0])
+	})
+	if err != nil {
+		player.SendMessage("%s%s", COLOR_RED, err.Error())
+		log.Println(err)
+		return
+	}
+
+	player.SendMessage("%sDeleting world %s...", COLOR_TEAL, args[0])
+}
+
+func handleRenameWorld(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 2 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/renameworld <name> <new name>", COLOR_RED)
+		return
+	}
+
+	err := server.RenameWorld(args[0], args[1], func(err error) {
+		if err != nil {
+			player.SendMessage("%sUnable to rename world %s", COLOR_RED, args[0])
+			log.Println(err)
+			return
+		}
+
+		player.SendMessage("%sRenamed world %s to %s", COLOR_GREEN, args[0], args[1])
+		log.Printf("%s has renamed world %s to %s\n", player.Username, args[0], args[1])
+	})
+	if err != nil {
+		player.SendMessage("%s%s", COLOR_RED, err.Error())
+		log.Println(err)
+	}
+}
+
+// The world the player is in, or nil with a message when it is a read-only copy such as a backup
+func editableWorld(player *Player) *World {
+	if player.World.ReadOnly {
+		player.SendMessage("%sThis world is read-only; use /restore back to return", COLOR_RED)
+		return nil
+	}
+
+	return player.World
+}
+
+func handleBlockInfo(server *ClassicServer, player *Player, args []string) {
+	if !player.HasExtension(packets.EXT_PLAYER_CLICK) {
+		player.SendMessage("%sYour client does not support clicking blocks", COLOR_RED)
+		return
+	}
+
+	player.SendMessage("%sClick a block to identify it", COLOR_TEAL)
+	player.OnNextClick(func(event *PlayerClickEvent) {
+		if !event.HasTargetBlock() {
+			player.SendMessage("%sNo block selected", COLOR_RED)
+			return
+		}
+
+		block := player.World.GetBlock(event.TargetBlockX, event.TargetBlockY, event.TargetBlockZ)
+		player.SendMessage(
+			"%sBlock at %d, %d, %d: %s (%d)",
+			COLOR_TEAL,
+			event.TargetBlockX,
+			event.TargetBlockY,
+			event.TargetBlockZ,
+			BlockName(block),
+			block,
+		)
+	})
+}
+
+func handleCheckpoint(server *ClassicServer, player *Player, args []string) {
+	if player.SetSpawnpoint(player.X, player.Y, player.Z, player.Yaw, player.Pitch) {
+		player.SendMessage("%sCheckpoint set", COLOR_GREEN)
+	} else {
+		player.SendMessage("%sYour client does not support checkpoints", COLOR_RED)
+	}
+}
+
 func handleKick(server *ClassicServer, player *Player, args []string) {
 	if len(args) < 1 {
 		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
@@ -238,25 +603,29 @@ func handleDeop(server *ClassicServer, player *Player, args []string) {
 }
 
 func handleSetSpawn(server *ClassicServer, player *Player, args []string) {
-	server.World.SpawnX = float64(player.X)
-	server.World.SpawnY = float64(player.Y)
-	server.World.SpawnZ = float64(player.Z)
-	server.World.SpawnYaw = player.Yaw
-	server.World.SpawnPitch = player.Pitch
-	world := *server.World
-
-	save := func() {
-		if err := SaveWorld(&world); err != nil {
-			player.SendMessage("%sWorld save failed", COLOR_RED)
-			log.Println(err)
-			log.Printf("Failed world save; attempted by %s via /setspawn\n", player.Username)
-		} else {
-			player.SendMessage("%sWorld spawn set", COLOR_TEAL)
-			log.Printf("World spawn set to X:%f Y:%f Z:%f\n", player.X, player.Y, player.Z)
-		}
+	live := editableWorld(player)
+	if live == nil {
+		return
 	}
 
-	go save()
+	live.SpawnX = float64(player.X)
+	live.SpawnY = float64(player.Y)
+	live.SpawnZ = float64(player.Z)
+	live.SpawnYaw = player.Yaw
+	live.SpawnPitch = player.Pitch
+
+	live.SaveInBackground(func(snapshot *World, err error) {
+		server.runOnMainLoop(func() {
+			if err != nil && err != ErrSaveSkipped {
+				player.SendMessage("%sWorld save failed", COLOR_RED)
+				log.Println(err)
+				log.Printf("Failed world save; attempted by %s via /setspawn\n", player.Username)
+			} else {
+				player.SendMessage("%sWorld spawn set", COLOR_TEAL)
+				log.Printf("World spawn set to X:%f Y:%f Z:%f\n", snapshot.SpawnX, snapshot.SpawnY, snapshot.SpawnZ)
+			}
+		})
+	})
 }
 
 func handleTp(server *ClassicServer, player *Player, args []string) {
@@ -280,25 +649,470 @@ func handleTp(server *ClassicServer, player *Player, args []string) {
 			return
 		}
 
-		from.Teleport(to.X, to.Y, to.Z, from.Yaw, from.Pitch)
+		if from.World != to.World {
+			if err := server.moveToWorld(from, to.World, to.X, to.Y, to.Z, from.Yaw, from.Pitch); err != nil {
+				player.SendMessage("%s%s", COLOR_RED, err.Error())
+				return
+			}
+		} else {
+			from.Teleport(to.X, to.Y, to.Z, from.Yaw, from.Pitch)
+		}
 		player.SendMessage("%sTeleporting %s to %s", COLOR_GREEN, fromUsername, toUsername)
 	}
 }
 
+func handleModel(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 2 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/model <username> <model>", COLOR_RED)
+	} else {
+		username := args[0]
+		model := strings.ToLower(args[1])
+
+		target := server.GetPlayerFromName(username)
+		if target == nil {
+			player.SendMessage("%sCould not find player \"%s\"", COLOR_RED, username)
+			return
+		}
+
+		target.SetModel(model)
+		player.SendMessage("%sChanged %s's model to %s", COLOR_GREEN, username, model)
+		log.Printf("%s has changed %s's model to %s\n", player.Username, username, model)
+	}
+}
+
+func handleHacks(server *ClassicServer, player *Player, args []string) {
+	world := editableWorld(player)
+	if world == nil {
+		return
+	}
+	hacks := &world.Hacks
+	if len(args) < 2 {
+		player.SendMessage("%sWorld Hacks:", COLOR_TEAL)
+		player.SendMessage("%s - flying: %t, noclip: %t, speeding: %t", COLOR_DARK_TEAL, hacks.Flying, hacks.NoClip, hacks.Speeding)
+		player.SendMessage("%s - spawncontrol: %t, thirdperson: %t", COLOR_DARK_TEAL, hacks.SpawnControl, hacks.ThirdPerson)
+		player.SendMessage("%s - jumpheight: %d", COLOR_DARK_TEAL, hacks.JumpHeight)
+		player.SendMessage("%sUsage: /hacks <hack> <value>", COLOR_TEAL)
+		return
+	}
+
+	hack := strings.ToLower(args[0])
+	value := args[1]
+
+	if hack == "jumpheight" {
+		parsed, err := strconv.ParseInt(value, 10, 16)
+		if err != nil {
+			player.SendMessage("%sJump height must be a number (-1 for default)", COLOR_RED)
+			return
+		}
+		hacks.JumpHeight = int16(parsed)
+	} else {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			player.SendMessage("%sValue must be true or false", COLOR_RED)
+			return
+		}
+
+		switch hack {
+		case "flying":
+			hacks.Flying = parsed
+		case "noclip":
+			hacks.NoClip = parsed
+		case "speeding":
+			hacks.Speeding = parsed
+		case "spawncontrol":
+			hacks.SpawnControl = parsed
+		case "thirdperson":
+			hacks.ThirdPerson = parsed
+		default:
+			player.SendMessage("%sUnknown hack \"%s\"", COLOR_RED, hack)
+			return
+		}
+	}
+
+	for _, other := range server.PlayersIn(world) {
+		other.SendHackControl(world)
+	}
+
+	world.markDirty()
+	server.saveWorldInBackground(world, nil)
+
+	player.SendMessage("%sSet %s to %s", COLOR_GREEN, hack, value)
+	log.Printf("%s has set world hack %s to %s\n", player.Username, hack, value)
+}
+
+func handleLaunch(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 4 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/launch <username> <x> <y> <z>", COLOR_RED)
+	} else {
+		username := args[0]
+		target := server.GetPlayerFromName(username)
+		if target == nil {
+			player.SendMessage("%sCould not find player \"%s\"", COLOR_RED, username)
+			return
+		}
+
+		velocity := [3]float64{}
+		for i := range velocity {
+			parsed, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+				player.SendMessage("%sVelocity must be numbers", COLOR_RED)
+				return
+			}
+			velocity[i] = parsed
+		}
+
+		if target.Launch(velocity[0], velocity[1], velocity[2]) {
+			player.SendMessage("%sLaunching %s", COLOR_GREEN, username)
+		} else {
+			player.SendMessage("%s%s's client does not support velocity control", COLOR_RED, username)
+		}
+	}
+}
+
+func handleEffect(server *ClassicServer, player *Player, args []string) {
+	if len(args) != 1 && len(args) != 4 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/effect <effect> [x y z]", COLOR_RED)
+		return
+	}
+
+	effect := server.GetParticleEffect(args[0])
+	if effect == nil {
+		names := []string{}
+		for _, effect := range server.ParticleEffects {
+			names = append(names, effect.Name)
+		}
+		player.SendMessage("%sUnknown effect \"%s\"", COLOR_RED, args[0])
+		player.SendMessage("%sEffects: %s", COLOR_RED, strings.Join(names, ", "))
+		return
+	}
+
+	position := [3]FPShort{player.X, player.Y, player.Z}
+	if len(args) == 4 {
+		for i := range position {
+			parsed, err := strconv.ParseFloat(args[i+1], 64)
+			if err != nil {
+				player.SendMessage("%sPosition must be numbers", COLOR_RED)
+				return
+			}
+			position[i] = FPShort(parsed)
+		}
+	}
+
+	server.SpawnEffect(player.World, effect, position[0], position[1], position[2], position[0], position[1], position[2])
+	player.SendMessage("%sSpawned %s", COLOR_GREEN, effect.Name)
+}
+
+func handleWeather(server *ClassicServer, player *Player, args []string) {
+	world := editableWorld(player)
+	if world == nil {
+		return
+	}
+	env := &world.Env
+	if len(args) < 1 {
+		player.SendMessage("%sWeather: %s (cycle %t)", COLOR_TEAL, weatherNames[env.Weather], env.WeatherCycle)
+		player.SendMessage("%sUsage: /weather <sun|rain|snow|cycle>", COLOR_TEAL)
+		return
+	}
+
+	if strings.ToLower(args[0]) == "cycle" {
+		env.WeatherCycle = true
+		player.SendMessage("%sWeather will now cycle", COLOR_GREEN)
+	} else {
+		weather, err := ParseWeather(args[0])
+		if err != nil {
+			player.SendMessage("%s%s", COLOR_RED, err.Error())
+			return
+		}
+
+		env.WeatherCycle = false
+		server.SetWeather(world, weather)
+		player.SendMessage("%sWeather set to %s", COLOR_GREEN, weatherNames[weather])
+	}
+
+	world.markDirty()
+	server.saveWorldInBackground(world, nil)
+
+	log.Printf("%s has set the weather to %s\n", player.Username, args[0])
+}
+
+func handlePalette(server *ClassicServer, player *Player, args []string) {
+	world := player.World
+	if len(args) < 1 {
+		if len(world.Palette) == 0 {
+			player.SendMessage("%sPalette: default", COLOR_TEAL)
+		} else {
+			player.SendMessage("%sPalette: %s", COLOR_TEAL, FormatPalette(world.Palette))
+		}
+		player.SendMessage("%sUsage: /palette <block ids...|reset>", COLOR_TEAL)
+		return
+	}
+
+	if world.ReadOnly {
+		player.SendMessage("%sThis world is read-only; use /restore back to return", COLOR_RED)
+		return
+	}
+
+	if strings.ToLower(args[0]) == "reset" {
+		world.Palette = []Block{}
+	} else {
+		palette, err := ParsePalette(strings.Join(args, ","))
+		if err != nil {
+			player.SendMessage("%s%s", COLOR_RED, err.Error())
+			return
+		}
+		world.Palette = palette
+	}
+
+	for _, other := range server.PlayersIn(world) {
+		other.SendInventoryOrder(world)
+	}
+
+	world.markDirty()
+	server.saveWorldInBackground(world, nil)
+
+	player.SendMessage("%sPalette updated", COLOR_GREEN)
+	log.Printf("%s has set the palette to %s\n", player.Username, strings.Join(args, " "))
+}
+
 func handleSaveWorld(server *ClassicServer, player *Player, args []string) {
+	live := editableWorld(player)
+	if live == nil {
+		return
+	}
+
 	player.SendMessage("%sSaving world...", COLOR_TEAL)
-	world := *server.World
+	backup := live.backupDue(server.Settings.BackupMinutes)
 
-	save := func() {
-		if err := SaveWorld(&world); err != nil {
-			player.SendMessage("%sWorld save failed", COLOR_RED)
-			log.Println(err)
-			log.Printf("Failed world save; attempted by %s via /saveworld\n", player.Username)
-		} else {
-			player.SendMessage("%sWorld saved", COLOR_GREEN)
-			log.Printf("World saved by %s\n", player.Username)
+	live.SaveInBackground(func(snapshot *World, err error) {
+		if err == nil && backup {
+			server.backupWorld(snapshot)
+		}
+
+		server.runOnMainLoop(func() {
+			if err != nil && err != ErrSaveSkipped {
+				player.SendMessage("%sWorld save failed", COLOR_RED)
+				log.Println(err)
+				log.Printf("Failed world save; attempted by %s via /saveworld\n", player.Username)
+			} else {
+				player.SendMessage("%sWorld saved", COLOR_GREEN)
+				log.Printf("World %s saved by %s\n", snapshot.Name, player.Username)
+			}
+		})
+	})
+}
+
+func handleImportWorld(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 1 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/importworld <file>", COLOR_RED)
+		return
+	}
+
+	live := editableWorld(player)
+	if live == nil {
+		return
+	}
+
+	path := WorldFilePath(args[0])
+	player.SendMessage("%sImporting world from %s...", COLOR_TEAL, path)
+
+	go func() {
+		world, err := ImportWorldFile(path)
+		server.runOnMainLoop(func() {
+			if err != nil {
+				player.SendMessage("%sUnable to import %s", COLOR_RED, path)
+				log.Println(err)
+				log.Printf("Failed world import of %s; attempted by %s via /importworld\n", path, player.Username)
+				return
+			}
+
+			// The world may have been deleted, renamed or replaced while the file was read. A rename in
+			// progress holds the save lock ReplaceWorld waits for, so the import is abandoned then too
+			if server.Worlds[live.Name] != live || server.worldPending(live.Name) {
+				player.SendMessage("%sWorld %s changed during the import, so it was abandoned", COLOR_RED, live.Name)
+				return
+			}
+
+			log.Printf("%s has imported world %s from %s\n", player.Username, live.Name, path)
+			for _, other := range server.PlayersIn(live) {
+				other.SendMessage("%sLoading world %s...", COLOR_TEAL, path)
+			}
+			server.ReplaceWorld(live, world)
+
+			player.SendMessage("%sHacks and the inventory order were reset to defaults", COLOR_YELLOW)
+			player.SendMessage("%sEnvironment settings not in the file were also reset", COLOR_YELLOW)
+			if unapplied := UnappliedClassicWorldMetadata(world); len(unapplied) > 0 {
+				player.SendMessage("%sKept for export but not applied: %s", COLOR_YELLOW, strings.Join(unapplied, ", "))
+			}
+
+			world.SaveInBackground(func(snapshot *World, err error) {
+				if err != nil && err != ErrSaveSkipped {
+					log.Println(err)
+				}
+			})
+		})
+	}()
+}
+
+func handleExportWorld(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 1 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/exportworld <file>", COLOR_RED)
+		return
+	}
+
+	path := WorldFilePath(args[0])
+	player.SendMessage("%sExporting world to %s...", COLOR_TEAL, path)
+	world := player.World.Snapshot()
+
+	go func() {
+		err := ExportWorldFile(world, path)
+		server.runOnMainLoop(func() {
+			if err != nil {
+				player.SendMessage("%sWorld export failed", COLOR_RED)
+				log.Println(err)
+				log.Printf("Failed world export to %s; attempted by %s via /exportworld\n", path, player.Username)
+			} else {
+				player.SendMessage("%sWorld exported", COLOR_GREEN)
+				log.Printf("World exported to %s by %s\n", path, player.Username)
+			}
+		})
+	}()
+}
+
+// Number of backups listed by /backups
+const BACKUPS_LISTED = 10
+
+func handleBackups(server *ClassicServer, player *Player, args []string) {
+	backups, err := ListBackups(player.World)
+	if err != nil {
+		player.SendMessage("%sUnable to list backups", COLOR_RED)
+		log.Println(err)
+		return
+	}
+
+	if len(backups) == 0 {
+		player.SendMessage("%sNo backups have been made", COLOR_TEAL)
+		return
+	}
+
+	player.SendMessage("%s%d backups, newest first:", COLOR_TEAL, len(backups))
+	for i, backup := range backups {
+		if i == BACKUPS_LISTED {
+			break
 		}
+		age := time.Since(backup.Time).Round(time.Minute)
+		player.SendMessage("%s %d) %s - %s ago", COLOR_DARK_TEAL, i+1, backup.Name, age)
 	}
+}
+
+func handleRestore(server *ClassicServer, player *Player, args []string) {
+	if len(args) < 1 {
+		player.SendMessage("%sInvalid command, Expected:", COLOR_RED)
+		player.SendMessage("%s/restore <backup> [view]", COLOR_RED)
+		player.SendMessage("%s/restore back", COLOR_RED)
+		return
+	}
+
+	if strings.ToLower(args[0]) == "back" {
+		if !player.World.ReadOnly {
+			player.SendMessage("%sYou are not viewing a backup", COLOR_RED)
+			return
+		}
+		server.StopViewing(player)
+		return
+	}
+
+	if len(args) > 1 && strings.ToLower(args[1]) == "view" {
+		restoreBackup(server, player, args[0], nil)
+		return
+	}
+
+	// A restore keeps the live world's settings, and backs it up first so the restore can be undone
+	server.GetWorld(player.World.Name, func(live *World, err error) {
+		if err != nil {
+			player.SendMessage("%s%s", COLOR_RED, err.Error())
+			return
+		}
+		if player.Conn != nil {
+			restoreBackup(server, player, args[0], live)
+		}
+	})
+}
+
+// Loads the named backup of the player's world in the background, then restores it over the live world,
+// or shows it to the player read-only if live is nil
+func restoreBackup(server *ClassicServer, player *Player, name string, live *World) {
+	view := live == nil
+	settingsFrom := player.World
+	if !view {
+		settingsFrom = live
+	}
+	snapshot := settingsFrom.Snapshot()
+
+	go func() {
+		backup, err := FindBackup(snapshot, name)
+		if err != nil {
+			server.runOnMainLoop(func() {
+				player.SendMessage("%s%s", COLOR_RED, err.Error())
+			})
+			return
+		}
+
+		world, err := LoadBackup(backup, snapshot)
+		if err != nil {
+			server.runOnMainLoop(func() {
+				player.SendMessage("%sUnable to load backup %s", COLOR_RED, backup.Name)
+				log.Println(err)
+			})
+			return
+		}
+
+		if view {
+			server.runOnMainLoop(func() {
+				if err := server.ViewWorld(player, world); err != nil {
+					log.Println(err)
+					return
+				}
+				player.SendMessage("%sViewing backup %s read-only; use /restore back to return", COLOR_GREEN, backup.Name)
+			})
+			return
+		}
+
+		if err := backupBeforeRestore(live, snapshot); err != nil {
+			server.runOnMainLoop(func() {
+				player.SendMessage("%sUnable to back up the world before restoring", COLOR_RED)
+				log.Println(err)
+			})
+			return
+		}
+
+		server.runOnMainLoop(func() {
+			// The world may have been deleted, renamed or replaced while the backup loaded, or be being renamed
+			if server.Worlds[live.Name] != live || server.worldPending(live.Name) {
+				player.SendMessage("%sWorld %s changed during the restore, so it was abandoned", COLOR_RED, live.Name)
+				return
+			}
+
+			log.Printf("%s has restored world %s from backup %s\n", player.Username, live.Name, backup.Name)
+			for _, other := range server.PlayersIn(live) {
+				other.SendMessage("%sRestoring world from backup %s...", COLOR_TEAL, backup.Name)
+			}
+			server.ReplaceWorld(live, world)
+			if player.World != world && player.World.ReadOnly && player.World.Name == world.Name {
+				if err := server.ChangeWorld(player, world); err != nil {
+					log.Println(err)
+				}
+			}
 
-	go save()
+			world.SaveInBackground(func(snapshot *World, err error) {
+				if err != nil && err != ErrSaveSkipped {
+					log.Println(err)
+				}
+			})
+		})
+	}()
 }
@@ -0,0 +1,299 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"classicserver/classic/nbt"
+	"classicserver/classic/packets"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+const CLASSIC_WORLD_EXTENSION = ".cw"
+
+// Sidecar holding ClassicWorld metadata the server doesn't use itself, such as block definitions, so exports keep it
+const WORLD_EXTRA_METADATA_FILENAME = "world.nbt"
+
+const CLASSIC_WORLD_VERSION int8 = 1
+
+// Names of the EnvColors compounds, indexed by their ENV_COLOR_ variable
+var classicWorldColorNames = [ENV_COLOR_COUNT]string{"Sky", "Cloud", "Fog", "Ambient", "Sunlight"}
+
+func ImportClassicWorld(path string) (*World, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	name, root, err := nbt.Read(gz)
+	if err != nil {
+		return nil, err
+	}
+	if name != "ClassicWorld" {
+		return nil, fmt.Errorf("Expected ClassicWorld root compound, got \"%s\"", name)
+	}
+
+	if version, _ := root.Byte("FormatVersion"); version != CLASSIC_WORLD_VERSION {
+		return nil, fmt.Errorf("Unsupported ClassicWorld version %d", version)
+	}
+
+	sizeX, _ := root.Short("X")
+	sizeY, _ := root.Short("Y")
+	sizeZ, _ := root.Short("Z")
+	volume, err := importWorldVolume(int(sizeX), int(sizeY), int(sizeZ))
+	if err != nil {
+		return nil, err
+	}
+
+	blockArray, _ := root.ByteArray("BlockArray")
+	if len(blockArray) != volume {
+		return nil, fmt.Errorf("BlockArray holds %d blocks, expected %d", len(blockArray), volume)
+	}
+
+	world := &World{
+		SizeX:  sizeX,
+		SizeY:  sizeY,
+		SizeZ:  sizeZ,
+		Hacks:  DefaultHackSettings(),
+		Env:    DefaultEnvSettings(),
+		Blocks: newBlocks(sizeX, sizeY, sizeZ),
+	}
+
+	// Upper bits of extended blocks, as written by ClassiCube
+	blockArray2, _ := root.ByteArray("BlockArray2")
+	if blockArray2 != nil && len(blockArray2) != len(blockArray) {
+		return nil, fmt.Errorf("BlockArray2 holds %d blocks, expected %d", len(blockArray2), len(blockArray))
+	}
+
+	for i := range world.Blocks {
+		block := Block(blockArray[i])
+		if blockArray2 != nil {
+			block |= Block(blockArray2[i]) << 8
+		}
+		if block > BLOCK_MAX_EXTENDED {
+			block = BLOCK_AIR
+		}
+		world.Blocks[i] = block
+	}
+
+	if spawn := root.Compound("Spawn"); spawn != nil {
+		spawnX, _ := spawn.Short("X")
+		spawnY, _ := spawn.Short("Y")
+		spawnZ, _ := spawn.Short("Z")
+		spawnYaw, _ := spawn.Byte("H")
+		spawnPitch, _ := spawn.Byte("P")
+		world.SpawnX = float64(spawnX) + 0.5
+		world.SpawnY = float64(spawnY)
+		world.SpawnZ = float64(spawnZ) + 0.5
+		world.SpawnYaw = uint8(spawnYaw)
+		world.SpawnPitch = uint8(spawnPitch)
+	} else {
+		world.SpawnX = float64(sizeX) / 2
+		world.SpawnY = float64(sizeY)
+		world.SpawnZ = float64(sizeZ) / 2
+	}
+
+	if metadata := root.Compound("Metadata"); metadata != nil {
+		world.ExtraMetadata = metadata
+		readClassicWorldMetadata(world, metadata.Compound("CPE"))
+	}
+
+	if unapplied := UnappliedClassicWorldMetadata(world); len(unapplied) > 0 {
+		log.Printf("Kept %s from %s for export without applying it\n", strings.Join(unapplied, ", "), path)
+	}
+
+	return world, nil
+}
+
+// CPE metadata the server applies to the world; the rest, such as block definitions, is only kept for export
+var classicWorldAppliedMetadata = []string{"EnvColors", "EnvWeatherType"}
+
+// Names of the imported CPE metadata the server keeps for export but doesn't apply to the world
+func UnappliedClassicWorldMetadata(world *World) []string {
+	unapplied := []string{}
+	for key := range world.ExtraMetadata.Compound("CPE") {
+		if !slices.Contains(classicWorldAppliedMetadata, key) {
+			unapplied = append(unapplied, key)
+		}
+	}
+
+	sort.Strings(unapplied)
+	return unapplied
+}
+
+func readClassicWorldMetadata(world *World, cpe nbt.Compound) {
+	if cpe == nil {
+		return
+	}
+
+	if envColors := cpe.Compound("EnvColors"); envColors != nil {
+		for i, name := range classicWorldColorNames {
+			if color := envColors.Compound(name); color != nil {
+				red, _ := color.Short("R")
+				green, _ := color.Short("G")
+				blue, _ := color.Short("B")
+				world.Env.Colors[i] = EnvColor{red, green, blue}
+				if red < 0 || green < 0 || blue < 0 || red > 255 || green > 255 || blue > 255 {
+					world.Env.Colors[i] = DEFAULT_ENV_COLOR
+				}
+			}
+		}
+	}
+
+	if envWeather := cpe.Compound("EnvWeatherType"); envWeather != nil {
+		weather, _ := envWeather.Byte("WeatherType")
+		if _, ok := weatherNames[uint8(weather)]; ok {
+			world.Env.Weather = uint8(weather)
+		}
+	}
+}
+
+func ExportClassicWorld(world *World, path string) error {
+	blockArray := make([]byte, len(world.Blocks))
+	blockArray2 := make([]byte, len(world.Blocks))
+	for i, block := range world.Blocks {
+		blockArray[i] = uint8(block)
+		blockArray2[i] = uint8(block >> 8)
+	}
+
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return err
+	}
+	uuid[6] = (uuid[6] & 0x0F) | 0x40
+	uuid[8] = (uuid[8] & 0x3F) | 0x80
+
+	now := time.Now().Unix()
+	root := nbt.Compound{
+		"FormatVersion": CLASSIC_WORLD_VERSION,
+		"Name":          strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		"UUID":          uuid,
+		"X":             world.SizeX,
+		"Y":             world.SizeY,
+		"Z":             world.SizeZ,
+		"Spawn": nbt.Compound{
+			"X": int16(math.Floor(world.SpawnX)),
+			"Y": int16(math.Floor(world.SpawnY)),
+			"Z": int16(math.Floor(world.SpawnZ)),
+			"H": int8(world.SpawnYaw),
+			"P": int8(world.SpawnPitch),
+		},
+		"BlockArray": blockArray,
+		"MapGenerator": nbt.Compound{
+			"Software": APP_NAME,
+		},
+		"TimeCreated":  now,
+		"LastModified": now,
+		"Metadata":     classicWorldMetadata(world),
+	}
+
+	if world.HasExtendedBlocks() {
+		root["BlockArray2"] = blockArray2
+	}
+
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	if err := nbt.Write(gz, "ClassicWorld", root); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+// Metadata kept from the imported world, with the CPE settings the server manages replaced by the world's own
+func classicWorldMetadata(world *World) nbt.Compound {
+	metadata := nbt.Compound{}
+	for key, value := range world.ExtraMetadata {
+		metadata[key] = value
+	}
+
+	cpe := nbt.Compound{}
+	for key, value := range world.ExtraMetadata.Compound("CPE") {
+		cpe[key] = value
+	}
+	metadata["CPE"] = cpe
+
+	envColors := nbt.Compound{
+		"ExtensionVersion": int32(1),
+	}
+	for i, name := range classicWorldColorNames {
+		color := world.Env.Colors[i]
+		envColors[name] = nbt.Compound{
+			"R": color.Red,
+			"G": color.Green,
+			"B": color.Blue,
+		}
+	}
+	cpe[packets.EXT_ENV_COLORS] = envColors
+
+	cpe[packets.EXT_ENV_WEATHER_TYPE] = nbt.Compound{
+		"ExtensionVersion": int32(1),
+		"WeatherType":      int8(world.Env.Weather),
+	}
+
+	return metadata
+}
+
+func LoadWorldExtraMetadata(world *World) error {
+	contents, err := os.ReadFile(world.Path(WORLD_EXTRA_METADATA_FILENAME))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(contents))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	_, metadata, err := nbt.Read(gz)
+	if err != nil {
+		return err
+	}
+
+	world.ExtraMetadata = metadata
+	return nil
+}
+
+func SaveWorldExtraMetadata(world *World) error {
+	if len(world.ExtraMetadata) == 0 {
+		if err := os.Remove(world.Path(WORLD_EXTRA_METADATA_FILENAME)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	if err := nbt.Write(gz, "Metadata", world.ExtraMetadata); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(world.Path(WORLD_EXTRA_METADATA_FILENAME), b.Bytes())
+}
@@ -0,0 +1,207 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"classicserver/classic/nbt"
+	"classicserver/classic/packets"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Writes a ClassicWorld file holding the root compound, as another server might
+func writeClassicWorld(t *testing.T, root nbt.Compound) string {
+	t.Helper()
+
+	var b bytes.Buffer
+	if err := nbt.Write(&b, "ClassicWorld", root); err != nil {
+		t.Fatal(err)
+	}
+	return writeGzipFile(t, "world.cw", b.Bytes())
+}
+
+func TestClassicWorldRoundTrip(t *testing.T) {
+	for _, maxBlock := range []Block{BLOCK_MAX_LEGACY, BLOCK_MAX_EXTENDED} {
+		world := newTestWorld(16, 8, 24, maxBlock)
+		world.Env.Weather = packets.WEATHER_SNOW
+		world.Env.Colors[packets.ENV_COLOR_SKY] = EnvColor{10, 20, 30}
+
+		path := filepath.Join(t.TempDir(), "world.cw")
+		if err := ExportClassicWorld(world, path); err != nil {
+			t.Fatal(err)
+		}
+
+		imported, err := ImportClassicWorld(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameWorld(t, imported, world)
+		if imported.Env.Weather != world.Env.Weather || imported.Env.Colors != world.Env.Colors {
+			t.Errorf("Environment is %+v, expected %+v", imported.Env, world.Env)
+		}
+	}
+}
+
+// ClassiCube writes the upper bits of extended blocks in BlockArray2, which must match BlockArray
+func TestImportClassicWorldBlockArray2(t *testing.T) {
+	root := nbt.Compound{
+		"FormatVersion": CLASSIC_WORLD_VERSION,
+		"X":             int16(2),
+		"Y":             int16(1),
+		"Z":             int16(1),
+		"BlockArray":    []byte{0x01, 0xFF},
+		"BlockArray2":   []byte{0x00, 0x03},
+	}
+	world, err := ImportClassicWorld(writeClassicWorld(t, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if world.GetBlock(0, 0, 0) != 0x001 || world.GetBlock(1, 0, 0) != 0x3FF {
+		t.Errorf("Blocks are %d and %d, expected 1 and %d", world.GetBlock(0, 0, 0), world.GetBlock(1, 0, 0), 0x3FF)
+	}
+
+	// Upper bits past the last extended block are unknown, and become air
+	root["BlockArray2"] = []byte{0x00, 0x04}
+	if world, err = ImportClassicWorld(writeClassicWorld(t, root)); err != nil {
+		t.Fatal(err)
+	}
+	if world.GetBlock(1, 0, 0) != BLOCK_AIR {
+		t.Errorf("Block %d past the last extended block was imported as %d, expected air", 0x4FF, world.GetBlock(1, 0, 0))
+	}
+
+	root["BlockArray2"] = []byte{0x00}
+	if _, err := ImportClassicWorld(writeClassicWorld(t, root)); err == nil {
+		t.Error("BlockArray2 shorter than BlockArray was imported")
+	}
+}
+
+// Only worlds with extended blocks need BlockArray2, which older clients' map tools don't expect
+func TestExportClassicWorldBlockArray2(t *testing.T) {
+	for _, maxBlock := range []Block{BLOCK_MAX_LEGACY, BLOCK_MAX_EXTENDED} {
+		path := filepath.Join(t.TempDir(), "world.cw")
+		if err := ExportClassicWorld(newTestWorld(4, 4, 4, maxBlock), path); err != nil {
+			t.Fatal(err)
+		}
+
+		_, root, err := nbt.Read(bytes.NewReader(readGzipFile(t, path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := root.ByteArray("BlockArray2"); ok != (maxBlock > BLOCK_MAX_LEGACY) {
+			t.Errorf("World with blocks up to %d has BlockArray2 %v", maxBlock, ok)
+		}
+	}
+}
+
+func TestImportClassicWorldMetadata(t *testing.T) {
+	definitions := nbt.Compound{"Block100": nbt.Compound{"ID": int8(100), "Name": "Marble"}}
+	root := nbt.Compound{
+		"FormatVersion": CLASSIC_WORLD_VERSION,
+		"X":             int16(4),
+		"Y":             int16(4),
+		"Z":             int16(4),
+		"BlockArray":    make([]byte, 64),
+		"Metadata": nbt.Compound{
+			"CPE": nbt.Compound{
+				"BlockDefinitions": definitions,
+				"EnvColors": nbt.Compound{
+					"Sky": nbt.Compound{"R": int16(1), "G": int16(2), "B": int16(3)},
+					"Fog": nbt.Compound{"R": int16(-1), "G": int16(300), "B": int16(3)},
+				},
+				"EnvWeatherType": nbt.Compound{"WeatherType": int8(99)},
+			},
+		},
+	}
+	world, err := ImportClassicWorld(writeClassicWorld(t, root))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if world.Env.Colors[packets.ENV_COLOR_SKY] != (EnvColor{1, 2, 3}) {
+		t.Errorf("Sky colour is %v, expected 1,2,3", world.Env.Colors[packets.ENV_COLOR_SKY])
+	}
+	if world.Env.Colors[packets.ENV_COLOR_FOG] != DEFAULT_ENV_COLOR {
+		t.Errorf("Fog colour out of range was imported as %v", world.Env.Colors[packets.ENV_COLOR_FOG])
+	}
+	if world.Env.Weather != DefaultEnvSettings().Weather {
+		t.Errorf("Unknown weather was imported as %d", world.Env.Weather)
+	}
+
+	if unapplied := UnappliedClassicWorldMetadata(world); !reflect.DeepEqual(unapplied, []string{"BlockDefinitions"}) {
+		t.Errorf("Unapplied metadata is %v, expected only BlockDefinitions", unapplied)
+	}
+
+	// No spawn is saved, so players spawn on top of the middle
+	if world.SpawnX != 2 || world.SpawnY != 4 || world.SpawnZ != 2 {
+		t.Errorf("Spawn is %v,%v,%v, expected 2,4,2", world.SpawnX, world.SpawnY, world.SpawnZ)
+	}
+
+	// Block definitions are kept for export, alongside the world's own colours
+	path := filepath.Join(t.TempDir(), "world.cw")
+	world.Env.Colors[packets.ENV_COLOR_SKY] = EnvColor{4, 5, 6}
+	if err := ExportClassicWorld(world, path); err != nil {
+		t.Fatal(err)
+	}
+	_, exported, err := nbt.Read(bytes.NewReader(readGzipFile(t, path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpe := exported.Compound("Metadata").Compound("CPE")
+	if !reflect.DeepEqual(cpe.Compound("BlockDefinitions"), definitions) {
+		t.Error("Block definitions weren't kept for export")
+	}
+	if sky := cpe.Compound("EnvColors").Compound("Sky"); !reflect.DeepEqual(sky, nbt.Compound{"R": int16(4), "G": int16(5), "B": int16(6)}) {
+		t.Errorf("Exported sky colour is %v, expected the world's 4,5,6", sky)
+	}
+}
+
+func TestImportClassicWorldInvalid(t *testing.T) {
+	valid := func() nbt.Compound {
+		return nbt.Compound{
+			"FormatVersion": CLASSIC_WORLD_VERSION,
+			"X":             int16(2),
+			"Y":             int16(2),
+			"Z":             int16(2),
+			"BlockArray":    make([]byte, 8),
+		}
+	}
+
+	for name, change := range map[string]func(root nbt.Compound){
+		"newer version":      func(root nbt.Compound) { root["FormatVersion"] = CLASSIC_WORLD_VERSION + 1 },
+		"no dimensions":      func(root nbt.Compound) { delete(root, "Y") },
+		"dimensions as ints": func(root nbt.Compound) { root["X"] = int32(2) },
+		"short BlockArray":   func(root nbt.Compound) { root["BlockArray"] = make([]byte, 7) },
+	} {
+		root := valid()
+		change(root)
+		if _, err := ImportClassicWorld(writeClassicWorld(t, root)); err == nil {
+			t.Errorf("ClassicWorld with %s was imported", name)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := nbt.Write(&b, "Schematic", valid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportClassicWorld(writeGzipFile(t, "world.cw", b.Bytes())); err == nil {
+		t.Error("NBT file with another root compound was imported")
+	}
+}
+
+func TestImportClassicWorldOversized(t *testing.T) {
+	// The volume of 2048x1024x2048 wraps to 0 as a uint32, matching an empty BlockArray
+	for _, size := range [][3]int16{{2048, 1024, 2048}, {math.MaxInt16, math.MaxInt16, math.MaxInt16}} {
+		root := nbt.Compound{
+			"FormatVersion": CLASSIC_WORLD_VERSION,
+			"X":             size[0],
+			"Y":             size[1],
+			"Z":             size[2],
+			"BlockArray":    []byte{},
+		}
+		if _, err := ImportClassicWorld(writeClassicWorld(t, root)); err == nil {
+			t.Errorf("ClassicWorld of %dx%dx%d was imported", size[0], size[1], size[2])
+		}
+	}
+}
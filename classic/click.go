@@ -0,0 +1,77 @@
+package classic
+
+import (
+	"classicserver/classic/packets"
+)
+
+type PlayerClickEvent struct {
+	Player         *Player
+	Button         uint8
+	Action         uint8
+	Yaw            int16
+	Pitch          int16
+	TargetEntityId int8
+	TargetBlockX   int16
+	TargetBlockY   int16
+	TargetBlockZ   int16
+	TargetFace     uint8
+}
+
+type PlayerClickHandler func(event *PlayerClickEvent)
+
+// Registers a handler run on the main loop for every click from clients supporting PlayerClick
+func (server *ClassicServer) AddClickHandler(handler PlayerClickHandler) {
+	server.clickHandlers = append(server.clickHandlers, handler)
+}
+
+// Runs the handler for the player's next click press instead of the registered handlers, for commands awaiting a selection
+func (player *Player) OnNextClick(handler PlayerClickHandler) {
+	player.clickCallback = handler
+}
+
+func (server *ClassicServer) HandleClick(player *Player, packet packets.UpstreamPlayerClick) {
+	event := &PlayerClickEvent{
+		Player:         player,
+		Button:         packet.Button,
+		Action:         packet.Action,
+		Yaw:            packet.Yaw,
+		Pitch:          packet.Pitch,
+		TargetEntityId: packet.TargetEntityId,
+		TargetBlockX:   packet.TargetBlockX,
+		TargetBlockY:   packet.TargetBlockY,
+		TargetBlockZ:   packet.TargetBlockZ,
+		TargetFace:     packet.TargetFace,
+	}
+
+	if player.clickCallback != nil && event.Action == packets.CLICK_ACTION_PRESS {
+		callback := player.clickCallback
+		player.clickCallback = nil
+		callback(event)
+		return
+	}
+
+	for _, handler := range server.clickHandlers {
+		handler(event)
+	}
+}
+
+// Player that was clicked on, or nil if no player was targeted. Only players in the same world are visible,
+// so an ID belonging to a player elsewhere isn't a target
+func (event *PlayerClickEvent) TargetPlayer() *Player {
+	if event.TargetEntityId == packets.CLICK_NO_ENTITY {
+		return nil
+	}
+
+	for _, player := range event.Player.Server.PlayersIn(event.Player.World) {
+		if player.Id == event.TargetEntityId {
+			return player
+		}
+	}
+
+	return nil
+}
+
+func (event *PlayerClickEvent) HasTargetBlock() bool {
+	return event.TargetFace != packets.FACE_NONE &&
+		event.Player.World.ValidBlock(event.TargetBlockX, event.TargetBlockY, event.TargetBlockZ)
+}
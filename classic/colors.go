@@ -0,0 +1,201 @@
+package classic
+
+import (
+	. "classicserver/classic/constants"
+	"classicserver/classic/packets"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const COLORS_FILENAME = "colors.txt"
+
+// Codes every client understands
+const STANDARD_COLOR_CODES = "0123456789abcdef"
+
+const (
+	CHAT_COLORS_ALL  = "all"
+	CHAT_COLORS_OP   = "op"
+	CHAT_COLORS_NONE = "none"
+)
+
+type TextColor struct {
+	Code     uint8
+	Red      uint8
+	Green    uint8
+	Blue     uint8
+	Alpha    uint8
+	Fallback uint8 // Standard code shown to clients without TextColors
+}
+
+func isStandardColorCode(code uint8) bool {
+	return strings.IndexByte(STANDARD_COLOR_CODES, code) != -1
+}
+
+// Whether the code after a COLOR_ESCAPE is a standard or defined custom colour
+func (server *ClassicServer) isColorCode(code uint8) bool {
+	_, custom := server.TextColors[code]
+	return isStandardColorCode(code) || custom
+}
+
+// Parses a "code=RRGGBB[AA],fallback" line
+func parseTextColor(line string) (*TextColor, error) {
+	segments := strings.SplitN(line, "=", 2)
+	if len(segments) != 2 || len(segments[0]) != 1 {
+		return nil, fmt.Errorf("Expected code=RRGGBB[AA],fallback")
+	}
+
+	code := segments[0][0]
+	// Uppercase hex digits are left alone, as some clients read them as the standard codes
+	if isStandardColorCode(code) || strings.IndexByte("ABCDEF", code) != -1 || code <= ' ' || code > '~' || code == '&' {
+		return nil, fmt.Errorf("Invalid colour code \"%c\"", code)
+	}
+
+	values := strings.Split(segments[1], ",")
+	if len(values) != 2 {
+		return nil, fmt.Errorf("Expected code=RRGGBB[AA],fallback")
+	}
+
+	hex := strings.TrimPrefix(strings.TrimSpace(values[0]), "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	rgba, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return nil, fmt.Errorf("Invalid colour \"%s\"", values[0])
+	}
+
+	fallback := strings.ToLower(strings.TrimSpace(values[1]))
+	if len(fallback) != 1 || !isStandardColorCode(fallback[0]) {
+		return nil, fmt.Errorf("Fallback must be one of %s", STANDARD_COLOR_CODES)
+	}
+
+	return &TextColor{
+		Code:     code,
+		Red:      uint8(rgba >> 24),
+		Green:    uint8(rgba >> 16),
+		Blue:     uint8(rgba >> 8),
+		Alpha:    uint8(rgba),
+		Fallback: fallback[0],
+	}, nil
+}
+
+func LoadTextColors() (map[uint8]TextColor, error) {
+	textColors := make(map[uint8]TextColor)
+	if _, err := os.Stat(COLORS_FILENAME); err != nil {
+		if os.IsNotExist(err) {
+			log.Println("Creating new colors.txt...")
+			contents := "# code=RRGGBB[AA],fallback - e.g. h=ff8800,6 for &h, shown as &6 to clients without TextColors\n"
+			return textColors, os.WriteFile(COLORS_FILENAME, []byte(contents), 0644)
+		} else {
+			return nil, err
+		}
+	}
+
+	contents, err := os.ReadFile(COLORS_FILENAME)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		textColor, err := parseTextColor(line)
+		if err != nil {
+			log.Println(err)
+			log.Printf("Unable to interpret colour \"%s\"\n", line)
+			continue
+		}
+
+		textColors[textColor.Code] = *textColor
+	}
+
+	return textColors, nil
+}
+
+func (player *Player) SendTextColors() {
+	if !player.HasExtension(packets.EXT_TEXT_COLORS) {
+		return
+	}
+
+	for _, textColor := range player.Server.TextColors {
+		player.Write(packets.NewDownstreamSetTextColor(
+			textColor.Red,
+			textColor.Green,
+			textColor.Blue,
+			textColor.Alpha,
+			textColor.Code,
+		))
+	}
+}
+
+// Whether the player may use colour codes in chat, per the chatColors setting
+func (player *Player) CanUseChatColors() bool {
+	switch player.Server.Settings.ChatColors {
+	case CHAT_COLORS_ALL:
+		return true
+	case CHAT_COLORS_OP:
+		return player.Mode == MODE_OP
+	default:
+		return false
+	}
+}
+
+// Removes every colour code, leaving any COLOR_ESCAPE not followed by a known code, as in "R&D".
+// Repeats until no codes are left, so codes split by another one, as in "&&aa", can't bypass the setting
+func (server *ClassicServer) StripColors(message string) string {
+	for {
+		var sb strings.Builder
+		for i := 0; i < len(message); i++ {
+			if message[i] == COLOR_ESCAPE[0] && i+1 < len(message) && server.isColorCode(message[i+1]) {
+				i++
+				continue
+			}
+			sb.WriteByte(message[i])
+		}
+
+		if sb.Len() == len(message) {
+			return message
+		}
+		message = sb.String()
+	}
+}
+
+// Rewrites colour codes into ones the client can display, replacing custom colours with their fallback
+// for clients without TextColors. Older clients crash on a COLOR_ESCAPE not followed by a known code,
+// so those escapes are dropped, keeping the text after them
+func (server *ClassicServer) SanitizeColors(message string, textColors bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		if message[i] != COLOR_ESCAPE[0] {
+			sb.WriteByte(message[i])
+			continue
+		}
+
+		if i+1 >= len(message) {
+			break
+		}
+
+		code := message[i+1]
+		if isStandardColorCode(code) {
+			sb.WriteByte(COLOR_ESCAPE[0])
+			sb.WriteByte(code)
+			i++
+		} else if textColor, ok := server.TextColors[code]; ok {
+			sb.WriteByte(COLOR_ESCAPE[0])
+			if textColors {
+				sb.WriteByte(code)
+			} else {
+				sb.WriteByte(textColor.Fallback)
+			}
+			i++
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,50 @@
+package classic
+
+import "testing"
+
+func TestStripColors(t *testing.T) {
+	server := &ClassicServer{
+		TextColors: map[uint8]TextColor{'h': {Code: 'h', Fallback: 'f'}},
+	}
+
+	for message, expected := range map[string]string{
+		"&aHello &fworld": "Hello world",
+		"&hCustom":        "Custom",
+		"R&D":             "R&D",
+		"a & b":           "a & b",
+		"&&a":             "&",
+		"&&aa":            "",
+		"&&&cc":           "&",
+		"ends with &":     "ends with &",
+		"&zunknown":       "&zunknown",
+	} {
+		if stripped := server.StripColors(message); stripped != expected {
+			t.Errorf("StripColors(%q) = %q, expected %q", message, stripped, expected)
+		}
+	}
+}
+
+func TestSanitizeColors(t *testing.T) {
+	server := &ClassicServer{
+		TextColors: map[uint8]TextColor{'h': {Code: 'h', Fallback: 'f'}},
+	}
+
+	for _, test := range []struct {
+		message    string
+		textColors bool
+		expected   string
+	}{
+		{"&aHello &fworld", false, "&aHello &fworld"},
+		{"&hCustom", true, "&hCustom"},
+		{"&hCustom", false, "&fCustom"},
+		{"R&D", false, "RD"},
+		{"a & b", false, "a  b"},
+		{"50&", false, "50"},
+		{"&&a", false, "&a"},
+		{"&zunknown", true, "zunknown"},
+	} {
+		if sanitized := server.SanitizeColors(test.message, test.textColors); sanitized != test.expected {
+			t.Errorf("SanitizeColors(%q, %v) = %q, expected %q", test.message, test.textColors, sanitized, test.expected)
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package constants
 
-type Block = uint8
+type Block = uint16
+
+// Highest block ID that fits in the 10 bits sent to clients supporting ExtendedBlocks
+const BLOCK_MAX_EXTENDED Block = 0x3FF
+
+// Highest block ID that fits in the byte sent to clients without ExtendedBlocks
+const BLOCK_MAX_LEGACY Block = 0xFF
 
 const (
 	BUILD_DESTROY = 0
@@ -9,6 +15,8 @@ const (
 
 var names map[Block]string
 
+var fallbacks = make(map[Block]uint8)
+
 const (
 	BLOCK_AIR Block = iota
 	BLOCK_STONE
@@ -115,3 +123,29 @@ func init() {
 	names[BLOCK_MOSSY_COBBLESTONE] = "Mossy Cobblestone"
 	names[BLOCK_OBSIDIAN] = "Obsidian"
 }
+
+func BlockName(block Block) string {
+	if name, ok := names[block]; ok {
+		return name
+	}
+
+	return "Unknown"
+}
+
+// Sets the block sent in place of an extended block to clients without ExtendedBlocks
+func SetFallbackBlock(block Block, fallback uint8) {
+	fallbacks[block] = fallback
+}
+
+// Block sent to clients without ExtendedBlocks, which is stone for extended blocks without a fallback
+func FallbackBlock(block Block) uint8 {
+	if block <= BLOCK_MAX_LEGACY {
+		return uint8(block)
+	}
+
+	if fallback, ok := fallbacks[block]; ok {
+		return fallback
+	}
+
+	return uint8(BLOCK_STONE)
+}
@@ -0,0 +1,101 @@
+package classic
+
+import (
+	"bufio"
+	"classicserver/classic/packets"
+	"errors"
+	"net"
+)
+
+const APP_NAME = "Go-Classic-Server"
+
+type Extension struct {
+	Name    string
+	Version int32
+}
+
+var SupportedExtensions = []Extension{
+	{packets.EXT_TWO_WAY_PING, 1},
+	{packets.EXT_CHANGE_MODEL, 1},
+	{packets.EXT_HACK_CONTROL, 1},
+	{packets.EXT_ENTITY_POSITIONS, 1},
+	{packets.EXT_FAST_MAP, 1},
+	{packets.EXT_BULK_BLOCK_UPDATE, 1},
+	{packets.EXT_PLAYER_CLICK, 1},
+	{packets.EXT_TEXT_HOTKEY, 1},
+	{packets.EXT_SET_SPAWNPOINT, 1},
+	{packets.EXT_VELOCITY_CONTROL, 1},
+	{packets.EXT_CUSTOM_PARTICLES, 1},
+	{packets.EXT_EXTENDED_BLOCKS, 1},
+	{packets.EXT_ENV_COLORS, 1},
+	{packets.EXT_ENV_WEATHER_TYPE, 1},
+	{packets.EXT_LIGHTING_MODE, 1},
+	{packets.EXT_TEXT_COLORS, 1},
+	{packets.EXT_PLUGIN_MESSAGES, 1},
+	{packets.EXT_INVENTORY_ORDER, 1},
+}
+
+func supportedVersion(name string) int32 {
+	for _, extension := range SupportedExtensions {
+		if extension.Name == name {
+			return extension.Version
+		}
+	}
+
+	return 0
+}
+
+// Exchanges ExtInfo and ExtEntry packets with a client that identified itself with CPE_MAGIC,
+// returning the extensions supported by both sides
+func negotiateExtensions(conn net.Conn, reader *bufio.Reader) (map[string]int32, error) {
+	extInfoPacket := packets.NewDownstreamExtInfo(APP_NAME, int16(len(SupportedExtensions)))
+	if err := extInfoPacket.Write(conn); err != nil {
+		return nil, err
+	}
+
+	for _, extension := range SupportedExtensions {
+		if err := packets.NewDownstreamExtEntry(extension.Name, extension.Version).Write(conn); err != nil {
+			return nil, err
+		}
+	}
+
+	packetId, err := packets.ReadPacketID(reader)
+	if err != nil {
+		return nil, err
+	}
+	if packetId != packets.UPSTREAM_EXT_INFO {
+		return nil, errors.New("Expected ExtInfo")
+	}
+
+	extInfo, err := packets.ReadUpstreamExtInfo(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	extensions := make(map[string]int32)
+	for i := int16(0); i < extInfo.ExtensionCount; i++ {
+		packetId, err := packets.ReadPacketID(reader)
+		if err != nil {
+			return nil, err
+		}
+		if packetId != packets.UPSTREAM_EXT_ENTRY {
+			return nil, errors.New("Expected ExtEntry")
+		}
+
+		extEntry, err := packets.ReadUpstreamExtEntry(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if version := supportedVersion(extEntry.ExtName); version != 0 && version == extEntry.Version {
+			extensions[extEntry.ExtName] = version
+		}
+	}
+
+	return extensions, nil
+}
+
+func (player *Player) HasExtension(name string) bool {
+	_, ok := player.Extensions[name]
+	return ok
+}
@@ -0,0 +1,210 @@
+package classic
+
+import (
+	"bufio"
+	. "classicserver/classic/constants"
+	"classicserver/classic/javaserial"
+	"classicserver/classic/packets"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	DAT_EXTENSION  = ".dat"
+	MINE_EXTENSION = ".mine"
+)
+
+// Written before levels saved by Minecraft Classic 0.0.13a and later
+const DAT_MAGIC uint32 = 0x271BB788
+
+const (
+	DAT_VERSION_RAW        uint8 = 1 // Name, creator and time, then the dimensions and blocks
+	DAT_VERSION_SERIALIZED uint8 = 2 // A serialized Level object
+)
+
+const DAT_LEVEL_CLASS = "com.mojang.minecraft.level.Level"
+
+// The earliest versions only saved the blocks of a 256x64x256 level
+const (
+	MINE_SIZE_X int16 = 256
+	MINE_SIZE_Y int16 = 64
+	MINE_SIZE_Z int16 = 256
+)
+
+// Imports a level saved by the original Minecraft Classic, where the width is X, the height is Z and the depth is Y
+func ImportDat(path string) (*World, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	reader := bufio.NewReader(gz)
+
+	header, err := reader.Peek(5)
+	if err != nil || binary.BigEndian.Uint32(header) != DAT_MAGIC {
+		return importMine(reader)
+	}
+	reader.Discard(5)
+
+	switch header[4] {
+	case DAT_VERSION_RAW:
+		return importRawDat(reader)
+	case DAT_VERSION_SERIALIZED:
+		return importSerializedDat(reader)
+	default:
+		return nil, fmt.Errorf("Unsupported level version %d", header[4])
+	}
+}
+
+func newDatWorld(width int, height int, depth int, blocks []byte) (*World, error) {
+	// The level's height runs along Z and its depth along Y
+	volume, err := importWorldVolume(width, depth, height)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(blocks) != volume {
+		return nil, fmt.Errorf("Level holds %d blocks, expected %d", len(blocks), volume)
+	}
+
+	world := &World{
+		SizeX:  int16(width),
+		SizeY:  int16(depth),
+		SizeZ:  int16(height),
+		Hacks:  DefaultHackSettings(),
+		Env:    DefaultEnvSettings(),
+		Blocks: newBlocks(int16(width), int16(depth), int16(height)),
+	}
+
+	for i := range world.Blocks {
+		block := Block(blocks[i])
+		if block > BLOCK_OBSIDIAN {
+			block = BLOCK_AIR
+		}
+		world.Blocks[i] = block
+	}
+
+	// Spawn in the middle, above the highest block, for levels that don't save it
+	world.SpawnX = math.Floor(float64(world.SizeX)/2.0) + 0.5
+	world.SpawnZ = math.Floor(float64(world.SizeZ)/2.0) + 0.5
+	world.SpawnY = float64(world.SizeY)
+	for y := world.SizeY - 1; y >= 0; y-- {
+		if world.GetBlock(int16(world.SpawnX), y, int16(world.SpawnZ)) != BLOCK_AIR {
+			world.SpawnY = float64(y) + 2
+			break
+		}
+	}
+
+	return world, nil
+}
+
+func importMine(reader *bufio.Reader) (*World, error) {
+	volume := int(MINE_SIZE_X) * int(MINE_SIZE_Y) * int(MINE_SIZE_Z)
+	blocks, err := io.ReadAll(io.LimitReader(reader, int64(volume)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) != volume {
+		return nil, errors.New("Not a Minecraft Classic level")
+	}
+
+	return newDatWorld(int(MINE_SIZE_X), int(MINE_SIZE_Z), int(MINE_SIZE_Y), blocks)
+}
+
+func importRawDat(reader *bufio.Reader) (*World, error) {
+	// Name and creator
+	for i := 0; i < 2; i++ {
+		length := make([]uint8, 2)
+		if _, err := io.ReadFull(reader, length); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(int(binary.BigEndian.Uint16(length))); err != nil {
+			return nil, err
+		}
+	}
+
+	// Creation time, then the width, height and depth
+	header := make([]uint8, 14)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	width := int(binary.BigEndian.Uint16(header[8:]))
+	height := int(binary.BigEndian.Uint16(header[10:]))
+	depth := int(binary.BigEndian.Uint16(header[12:]))
+
+	volume, err := importWorldVolume(width, depth, height)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := io.ReadAll(io.LimitReader(reader, int64(volume)))
+	if err != nil {
+		return nil, err
+	}
+
+	return newDatWorld(width, height, depth, blocks)
+}
+
+func importSerializedDat(reader *bufio.Reader) (*World, error) {
+	value, err := javaserial.ReadObject(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	level, ok := value.(*javaserial.Object)
+	if !ok || level.Class.Name != DAT_LEVEL_CLASS {
+		return nil, fmt.Errorf("Expected a serialized %s", DAT_LEVEL_CLASS)
+	}
+
+	width, _ := level.Int("width")
+	height, _ := level.Int("height")
+	depth, _ := level.Int("depth")
+	blocks, _ := level.Bytes("blocks")
+
+	world, err := newDatWorld(int(width), int(height), int(depth), blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	xSpawn, hasX := level.Int("xSpawn")
+	ySpawn, hasY := level.Int("ySpawn")
+	zSpawn, hasZ := level.Int("zSpawn")
+	inWorld := func(value int32, size int16) bool {
+		return value >= 0 && value < int32(size)
+	}
+	if hasX && hasY && hasZ && inWorld(xSpawn, world.SizeX) && inWorld(ySpawn, world.SizeY) && inWorld(zSpawn, world.SizeZ) {
+		world.SpawnX = float64(xSpawn) + 0.5
+		world.SpawnY = float64(ySpawn) + 1
+		world.SpawnZ = float64(zSpawn) + 0.5
+	}
+
+	// Degrees, where packed angles use 256 steps per turn
+	if rotSpawn, ok := level.Float("rotSpawn"); ok {
+		world.SpawnYaw = uint8(int(math.Round(float64(rotSpawn)*256/360)) & 0xFF)
+	}
+
+	colorFields := map[uint8]string{
+		packets.ENV_COLOR_SKY:   "skyColor",
+		packets.ENV_COLOR_FOG:   "fogColor",
+		packets.ENV_COLOR_CLOUD: "cloudColor",
+	}
+	for i, field := range colorFields {
+		if color, ok := level.Int(field); ok {
+			world.Env.Colors[i] = EnvColor{int16((color >> 16) & 0xFF), int16((color >> 8) & 0xFF), int16(color & 0xFF)}
+		}
+	}
+
+	return world, nil
+}
@@ -0,0 +1,257 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"classicserver/classic/javaserial"
+	"classicserver/classic/packets"
+	"encoding/binary"
+	"math"
+	"sort"
+	"testing"
+)
+
+// Level saved by Minecraft Classic 0.0.13a, with the blocks of the world
+func rawDat(world *World) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, DAT_MAGIC)
+	b.WriteByte(DAT_VERSION_RAW)
+	for _, text := range []string{"A level", "tester"} {
+		binary.Write(&b, binary.BigEndian, uint16(len(text)))
+		b.WriteString(text)
+	}
+	binary.Write(&b, binary.BigEndian, int64(0))
+	binary.Write(&b, binary.BigEndian, []uint16{uint16(world.SizeX), uint16(world.SizeZ), uint16(world.SizeY)})
+	b.Write(blocksAsBytes(world))
+	return b.Bytes()
+}
+
+// Level saved by later versions of Minecraft Classic, as a serialized object of the class with the int fields given
+func serializedDat(className string, ints map[string]int32, rotSpawn float32, blocks []byte) []byte {
+	var b bytes.Buffer
+	u16 := func(value uint16) { binary.Write(&b, binary.BigEndian, value) }
+	utf := func(value string) {
+		u16(uint16(len(value)))
+		b.WriteString(value)
+	}
+	classDesc := func(name string, fields []javaserial.Field) {
+		b.WriteByte(javaserial.TC_CLASSDESC)
+		utf(name)
+		b.Write(make([]byte, 8))
+		b.WriteByte(javaserial.SC_SERIALIZABLE)
+		u16(uint16(len(fields)))
+		for _, field := range fields {
+			b.WriteByte(field.Type)
+			utf(field.Name)
+			if field.ClassName != "" {
+				b.WriteByte(javaserial.TC_STRING)
+				utf(field.ClassName)
+			}
+		}
+		b.WriteByte(javaserial.TC_ENDBLOCKDATA)
+		b.WriteByte(javaserial.TC_NULL)
+	}
+
+	names := make([]string, 0, len(ints))
+	for name := range ints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	binary.Write(&b, binary.BigEndian, DAT_MAGIC)
+	b.WriteByte(DAT_VERSION_SERIALIZED)
+	u16(javaserial.STREAM_MAGIC)
+	u16(javaserial.STREAM_VERSION)
+
+	b.WriteByte(javaserial.TC_OBJECT)
+	fields := []javaserial.Field{{Type: 'F', Name: "rotSpawn"}}
+	for _, name := range names {
+		fields = append(fields, javaserial.Field{Type: 'I', Name: name})
+	}
+	fields = append(fields, javaserial.Field{Type: '[', Name: "blocks", ClassName: "[B"})
+	classDesc(className, fields)
+
+	binary.Write(&b, binary.BigEndian, math.Float32bits(rotSpawn))
+	for _, name := range names {
+		binary.Write(&b, binary.BigEndian, ints[name])
+	}
+	b.WriteByte(javaserial.TC_ARRAY)
+	classDesc("[B", nil)
+	binary.Write(&b, binary.BigEndian, uint32(len(blocks)))
+	b.Write(blocks)
+
+	return b.Bytes()
+}
+
+// Fields of a serialized level the size of the world, spawning at 3,4,5
+func datLevelFields(world *World) map[string]int32 {
+	return map[string]int32{
+		"width":  int32(world.SizeX),
+		"height": int32(world.SizeZ),
+		"depth":  int32(world.SizeY),
+		"xSpawn": 3,
+		"ySpawn": 4,
+		"zSpawn": 5,
+	}
+}
+
+func TestImportRawDat(t *testing.T) {
+	world := newTestWorld(16, 8, 24, BLOCK_OBSIDIAN)
+	imported, err := ImportDat(writeGzipFile(t, "level.dat", rawDat(world)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Raw levels don't save a spawn, so it's above the highest block in the middle
+	for y := world.SizeY - 1; y >= 0; y-- {
+		if world.GetBlock(8, y, 12) != BLOCK_AIR {
+			world.SpawnY = float64(y) + 2
+			break
+		}
+	}
+	world.SpawnYaw, world.SpawnPitch = 0, 0
+	assertSameWorld(t, imported, world)
+}
+
+func TestImportSerializedDat(t *testing.T) {
+	world := newTestWorld(16, 8, 24, BLOCK_OBSIDIAN)
+	data := serializedDat(DAT_LEVEL_CLASS, datLevelFields(world), 90, blocksAsBytes(world))
+	imported, err := ImportDat(writeGzipFile(t, "level.dat", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	world.SpawnX, world.SpawnY, world.SpawnZ = 3.5, 5, 5.5
+	world.SpawnYaw, world.SpawnPitch = 64, 0
+	assertSameWorld(t, imported, world)
+}
+
+func TestImportSerializedDatSpawnOutside(t *testing.T) {
+	world := newTestWorld(16, 8, 24, BLOCK_OBSIDIAN)
+	blocks := make([]byte, world.Volume())
+
+	// Spawns that would wrap into the world if truncated to 16 bits
+	for _, spawn := range [][3]int32{{65536 + 3, 4, 5}, {3, -65536 + 4, 5}, {3, 4, 24}} {
+		fields := datLevelFields(world)
+		fields["xSpawn"], fields["ySpawn"], fields["zSpawn"] = spawn[0], spawn[1], spawn[2]
+		imported, err := ImportDat(writeGzipFile(t, "level.dat", serializedDat(DAT_LEVEL_CLASS, fields, 0, blocks)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported.SpawnX != 8.5 || imported.SpawnZ != 12.5 {
+			t.Errorf("Spawn outside the level %v was kept as %v,%v,%v", spawn, imported.SpawnX, imported.SpawnY, imported.SpawnZ)
+		}
+	}
+}
+
+// The spawn rotation is saved in degrees, which can be negative or more than a turn
+func TestImportSerializedDatRotSpawn(t *testing.T) {
+	world := newTestWorld(4, 4, 4, BLOCK_OBSIDIAN)
+	for rotSpawn, expected := range map[float32]uint8{0: 0, 180: 128, 359: 255, 360: 0, 450: 64, -90: 192, -720: 0} {
+		data := serializedDat(DAT_LEVEL_CLASS, datLevelFields(world), rotSpawn, blocksAsBytes(world))
+		imported, err := ImportDat(writeGzipFile(t, "level.dat", data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported.SpawnYaw != expected {
+			t.Errorf("Spawn rotated %v degrees was imported as %d, expected %d", rotSpawn, imported.SpawnYaw, expected)
+		}
+	}
+}
+
+func TestImportSerializedDatColors(t *testing.T) {
+	world := newTestWorld(4, 4, 4, BLOCK_OBSIDIAN)
+	fields := datLevelFields(world)
+	fields["skyColor"] = 0x99CCFF
+	fields["fogColor"] = 0x102030
+	data := serializedDat(DAT_LEVEL_CLASS, fields, 0, blocksAsBytes(world))
+	imported, err := ImportDat(writeGzipFile(t, "level.dat", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range map[uint8]EnvColor{
+		packets.ENV_COLOR_SKY:   {0x99, 0xCC, 0xFF},
+		packets.ENV_COLOR_FOG:   {0x10, 0x20, 0x30},
+		packets.ENV_COLOR_CLOUD: DEFAULT_ENV_COLOR,
+	} {
+		if imported.Env.Colors[i] != expected {
+			t.Errorf("Colour %d was imported as %v, expected %v", i, imported.Env.Colors[i], expected)
+		}
+	}
+}
+
+func TestImportSerializedDatWrongClass(t *testing.T) {
+	world := newTestWorld(4, 4, 4, BLOCK_OBSIDIAN)
+	data := serializedDat("com.mojang.minecraft.player.Player", datLevelFields(world), 0, blocksAsBytes(world))
+	if _, err := ImportDat(writeGzipFile(t, "level.dat", data)); err == nil {
+		t.Error("Serialized object of another class was imported as a level")
+	}
+}
+
+// The earliest levels are only the blocks of a 256x64x256 level, without a header
+func TestImportMine(t *testing.T) {
+	world := newTestWorld(MINE_SIZE_X, MINE_SIZE_Y, MINE_SIZE_Z, BLOCK_OBSIDIAN)
+	raw := blocksAsBytes(world)
+	imported, err := ImportDat(writeGzipFile(t, "level.mine", raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.SizeX != MINE_SIZE_X || imported.SizeY != MINE_SIZE_Y || imported.SizeZ != MINE_SIZE_Z {
+		t.Fatalf("World is %dx%dx%d, expected %dx%dx%d", imported.SizeX, imported.SizeY, imported.SizeZ, MINE_SIZE_X, MINE_SIZE_Y, MINE_SIZE_Z)
+	}
+	if !bytes.Equal(raw, blocksAsBytes(imported)) {
+		t.Error("Blocks differ from the level")
+	}
+
+	if _, err := ImportDat(writeGzipFile(t, "level.mine", raw[1:])); err == nil {
+		t.Error("Level without a header and a block short of 256x64x256 was imported")
+	}
+}
+
+// Blocks after obsidian were only added once levels were saved in another format
+func TestImportDatUnknownBlocks(t *testing.T) {
+	world := newTestWorld(4, 4, 4, BLOCK_OBSIDIAN)
+	world.SetBlock(0, 0, 0, BLOCK_OBSIDIAN+1)
+	world.SetBlock(1, 0, 0, 0xFF)
+	imported, err := ImportDat(writeGzipFile(t, "level.dat", rawDat(world)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := int16(0); x < 2; x++ {
+		if block := imported.GetBlock(x, 0, 0); block != BLOCK_AIR {
+			t.Errorf("Unknown block %d was imported as %d, expected air", world.GetBlock(x, 0, 0), block)
+		}
+	}
+}
+
+func TestImportDatOversized(t *testing.T) {
+	// Claims a 32767x64x32767 level, but only holds a few blocks
+	world := newTestWorld(4, 4, 4, BLOCK_OBSIDIAN)
+	data := rawDat(world)
+	sizes := bytes.Index(data, []byte{0, 4, 0, 4, 0, 4})
+	binary.BigEndian.PutUint16(data[sizes:], math.MaxInt16)
+	binary.BigEndian.PutUint16(data[sizes+2:], math.MaxInt16)
+	binary.BigEndian.PutUint16(data[sizes+4:], 64)
+	if _, err := ImportDat(writeGzipFile(t, "level.dat", data)); err == nil {
+		t.Error("Raw level larger than the import limit was imported")
+	}
+
+	// The volume of 2048x2048x1024 wraps to 0 as a uint32
+	fields := map[string]int32{"width": 2048, "height": 2048, "depth": 1024}
+	if _, err := ImportDat(writeGzipFile(t, "level.dat", serializedDat(DAT_LEVEL_CLASS, fields, 0, nil))); err == nil {
+		t.Error("Serialized level larger than the import limit was imported")
+	}
+}
+
+func blocksAsBytes(world *World) []byte {
+	raw := make([]byte, 0, world.Volume())
+	for y := int16(0); y < world.SizeY; y++ {
+		for z := int16(0); z < world.SizeZ; z++ {
+			for x := int16(0); x < world.SizeX; x++ {
+				raw = append(raw, uint8(world.GetBlock(x, y, z)))
+			}
+		}
+	}
+	return raw
+}
@@ -0,0 +1,222 @@
+package classic
+
+import (
+	"classicserver/classic/packets"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weatherNames = map[uint8]string{
+	packets.WEATHER_SUN:  "sun",
+	packets.WEATHER_RAIN: "rain",
+	packets.WEATHER_SNOW: "snow",
+}
+
+var lightingNames = map[uint8]string{
+	packets.LIGHTING_DEFAULT: "default",
+	packets.LIGHTING_CLASSIC: "classic",
+	packets.LIGHTING_FANCY:   "fancy",
+}
+
+// Names of the colours, indexed by their ENV_COLOR_ variable
+var envColorNames = [ENV_COLOR_COUNT]string{"sky", "cloud", "fog", "shadow", "sun"}
+
+const ENV_COLOR_COUNT = 5
+
+type EnvColor struct {
+	Red   int16
+	Green int16
+	Blue  int16
+}
+
+// Leaves the colour up to the client
+var DEFAULT_ENV_COLOR = EnvColor{-1, -1, -1}
+
+func ParseEnvColor(value string) (EnvColor, error) {
+	if strings.ToLower(value) == "default" {
+		return DEFAULT_ENV_COLOR, nil
+	}
+
+	parsed, err := strconv.ParseUint(strings.TrimPrefix(value, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(value, "#")) != 6 {
+		return DEFAULT_ENV_COLOR, fmt.Errorf("Invalid colour \"%s\", expected RRGGBB or default", value)
+	}
+
+	return EnvColor{int16(parsed >> 16), int16((parsed >> 8) & 0xFF), int16(parsed & 0xFF)}, nil
+}
+
+func (color EnvColor) String() string {
+	if color == DEFAULT_ENV_COLOR {
+		return "default"
+	}
+
+	return fmt.Sprintf("%02x%02x%02x", color.Red, color.Green, color.Blue)
+}
+
+func ParseWeather(value string) (uint8, error) {
+	for weather, name := range weatherNames {
+		if name == strings.ToLower(value) {
+			return weather, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown weather \"%s\", expected sun, rain or snow", value)
+}
+
+func ParseLightingMode(value string) (uint8, error) {
+	for mode, name := range lightingNames {
+		if name == strings.ToLower(value) {
+			return mode, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown lighting mode \"%s\", expected default, classic or fancy", value)
+}
+
+type EnvSettings struct {
+	Weather        uint8
+	Colors         [ENV_COLOR_COUNT]EnvColor
+	LightingMode   uint8
+	LightingLocked bool
+	DayCycle       bool // Replaces the colours with ones following the time of day
+	DayMinutes     int  // Real time length of a day in the cycle
+	WeatherCycle   bool // Changes the weather at random intervals
+
+	nextWeatherChange time.Time
+}
+
+func DefaultEnvSettings() EnvSettings {
+	settings := EnvSettings{
+		Weather:      packets.WEATHER_SUN,
+		LightingMode: packets.LIGHTING_DEFAULT,
+		DayMinutes:   24 * 60,
+	}
+
+	for i := range settings.Colors {
+		settings.Colors[i] = DEFAULT_ENV_COLOR
+	}
+
+	return settings
+}
+
+type dayCycleKeyframe struct {
+	phase  float64
+	colors [ENV_COLOR_COUNT]uint32
+}
+
+// Colours through the day cycle, where 0 is midnight and 0.5 is midday
+var dayCycleKeyframes = []dayCycleKeyframe{
+	{0.00, [ENV_COLOR_COUNT]uint32{0x0B1030, 0x303050, 0x101020, 0x202040, 0x404060}},
+	{0.25, [ENV_COLOR_COUNT]uint32{0xF0A070, 0xFFD0B0, 0xE0A080, 0x806060, 0xFFC090}},
+	{0.35, [ENV_COLOR_COUNT]uint32{0x99CCFF, 0xFFFFFF, 0xFFFFFF, 0x9B9B9B, 0xFFFFFF}},
+	{0.65, [ENV_COLOR_COUNT]uint32{0x99CCFF, 0xFFFFFF, 0xFFFFFF, 0x9B9B9B, 0xFFFFFF}},
+	{0.75, [ENV_COLOR_COUNT]uint32{0xE07050, 0xD09080, 0xC07060, 0x705050, 0xE0A080}},
+	{1.00, [ENV_COLOR_COUNT]uint32{0x0B1030, 0x303050, 0x101020, 0x202040, 0x404060}},
+}
+
+func lerpColor(from uint32, to uint32, t float64) EnvColor {
+	lerp := func(shift uint32) int16 {
+		a := float64((from >> shift) & 0xFF)
+		b := float64((to >> shift) & 0xFF)
+		return int16(a + (b-a)*t)
+	}
+
+	return EnvColor{lerp(16), lerp(8), lerp(0)}
+}
+
+// Colours currently shown in the world, following the day cycle if enabled
+func (env *EnvSettings) CurrentColors(now time.Time) [ENV_COLOR_COUNT]EnvColor {
+	if !env.DayCycle || env.DayMinutes <= 0 {
+		return env.Colors
+	}
+
+	period := int64(env.DayMinutes) * int64(time.Minute)
+	phase := float64(now.UnixNano()%period) / float64(period)
+
+	colors := [ENV_COLOR_COUNT]EnvColor{}
+	for i := 1; i < len(dayCycleKeyframes); i++ {
+		from := dayCycleKeyframes[i-1]
+		to := dayCycleKeyframes[i]
+		if phase <= to.phase {
+			t := (phase - from.phase) / (to.phase - from.phase)
+			for j := range colors {
+				colors[j] = lerpColor(from.colors[j], to.colors[j], t)
+			}
+			break
+		}
+	}
+
+	return colors
+}
+
+func (player *Player) SendWeather(world *World) {
+	if player.HasExtension(packets.EXT_ENV_WEATHER_TYPE) {
+		player.Write(packets.NewDownstreamEnvSetWeatherType(world.Env.Weather))
+	}
+}
+
+func (player *Player) SendEnvColors(colors [ENV_COLOR_COUNT]EnvColor) {
+	if !player.HasExtension(packets.EXT_ENV_COLORS) {
+		return
+	}
+
+	for i, color := range colors {
+		player.Write(packets.NewDownstreamEnvSetColor(uint8(i), color.Red, color.Green, color.Blue))
+	}
+}
+
+func (player *Player) SendEnvironment(world *World) {
+	player.SendWeather(world)
+	player.SendEnvColors(world.Env.CurrentColors(time.Now()))
+
+	if player.HasExtension(packets.EXT_LIGHTING_MODE) {
+		player.Write(packets.NewDownstreamLightingMode(world.Env.LightingMode, world.Env.LightingLocked))
+	}
+}
+
+func (server *ClassicServer) SetWeather(world *World, weather uint8) {
+	world.Env.Weather = weather
+	for _, player := range server.PlayersIn(world) {
+		player.SendWeather(world)
+	}
+}
+
+func NewEnvironmentTicker() *time.Ticker {
+	return time.NewTicker(time.Second * 10)
+}
+
+// Advances the day and weather cycles of the world
+func (server *ClassicServer) UpdateEnvironment(world *World) {
+	now := time.Now()
+
+	if world.Env.DayCycle {
+		colors := world.Env.CurrentColors(now)
+		for _, player := range server.PlayersIn(world) {
+			player.SendEnvColors(colors)
+		}
+	}
+
+	if world.Env.WeatherCycle {
+		if world.Env.nextWeatherChange.IsZero() {
+			world.Env.nextWeatherChange = now.Add(randomWeatherDuration())
+		} else if now.After(world.Env.nextWeatherChange) {
+			world.Env.nextWeatherChange = now.Add(randomWeatherDuration())
+
+			// Mostly clear, with rain more likely than snow
+			weather := packets.WEATHER_SUN
+			if roll := rand.Intn(100); roll >= 85 {
+				weather = packets.WEATHER_SNOW
+			} else if roll >= 60 {
+				weather = packets.WEATHER_RAIN
+			}
+			server.SetWeather(world, weather)
+		}
+	}
+}
+
+func randomWeatherDuration() time.Duration {
+	return time.Duration(5+rand.Intn(16)) * time.Minute
+}
@@ -0,0 +1,58 @@
+package classic
+
+import (
+	. "classicserver/classic/constants"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const FALLBACKS_FILENAME = "fallbacks.txt"
+
+// Loads "block=fallback" lines, setting the blocks sent in place of extended blocks to clients without ExtendedBlocks
+func LoadBlockFallbacks() error {
+	if _, err := os.Stat(FALLBACKS_FILENAME); err != nil {
+		if os.IsNotExist(err) {
+			log.Println("Creating new fallbacks.txt...")
+			contents := "# block=fallback - blocks above 255 are sent as their fallback, or stone, to clients without ExtendedBlocks\n"
+			return os.WriteFile(FALLBACKS_FILENAME, []byte(contents), 0644)
+		} else {
+			return err
+		}
+	}
+
+	contents, err := os.ReadFile(FALLBACKS_FILENAME)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		segments := strings.SplitN(line, "=", 2)
+		if len(segments) != 2 {
+			log.Println("Unable to interpret fallback \"", line, "\"")
+			continue
+		}
+
+		block, err := strconv.ParseUint(strings.TrimSpace(segments[0]), 10, 16)
+		if err != nil || Block(block) > BLOCK_MAX_EXTENDED {
+			log.Printf("Unable to interpret fallback block \"%s\"\n", segments[0])
+			continue
+		}
+
+		fallback, err := strconv.ParseUint(strings.TrimSpace(segments[1]), 10, 8)
+		if err != nil {
+			log.Printf("Unable to interpret fallback \"%s\" for block %d\n", segments[1], block)
+			continue
+		}
+
+		SetFallbackBlock(Block(block), uint8(fallback))
+	}
+
+	return nil
+}
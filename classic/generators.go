@@ -0,0 +1,167 @@
+package classic
+
+import (
+	. "classicserver/classic/constants"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Fills an empty world with terrain and sets its spawn, the same way for the same seed
+type WorldGenerator func(world *World, seed int64)
+
+var WorldGenerators = map[string]WorldGenerator{
+	"empty": GenerateEmpty,
+	"flat":  GenerateFlat,
+	"hills": GenerateHills,
+}
+
+func WorldGeneratorNames() []string {
+	names := make([]string, 0, len(WorldGenerators))
+	for name := range WorldGenerators {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func GetWorldGenerator(name string) (WorldGenerator, error) {
+	generator, ok := WorldGenerators[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown generator \"%s\", expected one of %s", name, strings.Join(WorldGeneratorNames(), ", "))
+	}
+
+	return generator, nil
+}
+
+// Leaves the world as air, spawning in the middle
+func GenerateEmpty(world *World, seed int64) {
+	world.SpawnX = math.Floor(float64(world.SizeX)/2.0) + 0.5
+	world.SpawnY = math.Floor(float64(world.SizeY)/2.0) + 2
+	world.SpawnZ = math.Floor(float64(world.SizeZ)/2.0) + 0.5
+}
+
+// Dirt up to just above half the height of the world, topped with grass
+func GenerateFlat(world *World, seed int64) {
+	surface := world.SizeY/2 + 1
+	if surface > world.SizeY-1 {
+		surface = world.SizeY - 1
+	}
+
+	// Each layer of the world is contiguous
+	layer := int(world.SizeX) * int(world.SizeZ)
+	for y := int16(0); y <= surface; y++ {
+		block := BLOCK_DIRT
+		if y == surface {
+			block = BLOCK_GRASS_BLOCK
+		}
+		blocks := world.Blocks[int(y)*layer : int(y+1)*layer]
+		for i := range blocks {
+			blocks[i] = block
+		}
+	}
+
+	setSpawnAboveSurface(world)
+}
+
+// Rolling grass hills over stone, with water filling the valleys below half the height of the world
+func GenerateHills(world *World, seed int64) {
+	random := rand.New(rand.NewSource(seed))
+	large := newValueNoise(random, world.SizeX, world.SizeZ, 32)
+	small := newValueNoise(random, world.SizeX, world.SizeZ, 8)
+
+	waterLevel := world.SizeY / 2
+	amplitude := float64(world.SizeY) / 4
+
+	for z := int16(0); z < world.SizeZ; z++ {
+		for x := int16(0); x < world.SizeX; x++ {
+			noise := large.At(x, z)*0.75 + small.At(x, z)*0.25
+			height := int16(float64(waterLevel) + (noise*2-1)*amplitude)
+			if height < 0 {
+				height = 0
+			} else if height > world.SizeY-1 {
+				height = world.SizeY - 1
+			}
+
+			for y := int16(0); y <= height; y++ {
+				block := BLOCK_STONE
+				switch {
+				case y == height && height < waterLevel:
+					block = BLOCK_SAND
+				case y == height:
+					block = BLOCK_GRASS_BLOCK
+				case y > height-4:
+					block = BLOCK_DIRT
+				}
+				world.Blocks[world.BlockIndex(x, y, z)] = block
+			}
+
+			for y := height + 1; y < waterLevel; y++ {
+				world.Blocks[world.BlockIndex(x, y, z)] = BLOCK_WATER_STATIONARY
+			}
+		}
+	}
+
+	setSpawnAboveSurface(world)
+}
+
+// Spawns in the middle of the world, standing on the highest block
+func setSpawnAboveSurface(world *World) {
+	world.SpawnX = math.Floor(float64(world.SizeX)/2.0) + 0.5
+	world.SpawnZ = math.Floor(float64(world.SizeZ)/2.0) + 0.5
+	for y := world.SizeY - 1; y >= 0; y-- {
+		if world.GetBlock(int16(world.SpawnX), y, int16(world.SpawnZ)) != BLOCK_AIR {
+			world.SpawnY = float64(y) + 2
+			return
+		}
+	}
+	world.SpawnY = 2
+}
+
+// Random values on a grid, smoothly interpolated between grid points
+type valueNoise struct {
+	spacing int16
+	width   int16
+	values  []float64
+}
+
+func newValueNoise(random *rand.Rand, sizeX int16, sizeZ int16, spacing int16) *valueNoise {
+	width := sizeX/spacing + 2
+	depth := sizeZ/spacing + 2
+	values := make([]float64, int(width)*int(depth))
+	for i := range values {
+		values[i] = random.Float64()
+	}
+
+	return &valueNoise{
+		spacing: spacing,
+		width:   width,
+		values:  values,
+	}
+}
+
+// Noise between 0 and 1 at the block position
+func (noise *valueNoise) At(x int16, z int16) float64 {
+	cellX, cellZ := x/noise.spacing, z/noise.spacing
+	fracX := smoothStep(float64(x%noise.spacing) / float64(noise.spacing))
+	fracZ := smoothStep(float64(z%noise.spacing) / float64(noise.spacing))
+
+	value := func(x int16, z int16) float64 {
+		return noise.values[int(z)*int(noise.width)+int(x)]
+	}
+
+	top := lerp(value(cellX, cellZ), value(cellX+1, cellZ), fracX)
+	bottom := lerp(value(cellX, cellZ+1), value(cellX+1, cellZ+1), fracX)
+	return lerp(top, bottom, fracZ)
+}
+
+func smoothStep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+func lerp(a float64, b float64, t float64) float64 {
+	return a + (b-a)*t
+}
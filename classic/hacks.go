@@ -0,0 +1,82 @@
+package classic
+
+import (
+	"classicserver/classic/packets"
+	"fmt"
+	"strings"
+)
+
+type HackSettings struct {
+	Flying       bool
+	NoClip       bool
+	Speeding     bool
+	SpawnControl bool
+	ThirdPerson  bool
+	JumpHeight   int16 // -1 for the client default
+}
+
+func DefaultHackSettings() HackSettings {
+	return HackSettings{
+		Flying:       true,
+		NoClip:       true,
+		Speeding:     true,
+		SpawnControl: true,
+		ThirdPerson:  true,
+		JumpHeight:   -1,
+	}
+}
+
+// MOTD flags understood by clients without HackControl
+func (hacks HackSettings) MOTDFlags() string {
+	flags := []string{}
+	if !hacks.Flying {
+		flags = append(flags, "-fly")
+	}
+	if !hacks.NoClip {
+		flags = append(flags, "-noclip")
+	}
+	if !hacks.Speeding {
+		flags = append(flags, "-speed")
+	}
+	if !hacks.SpawnControl {
+		flags = append(flags, "-respawn")
+	}
+	if !hacks.ThirdPerson {
+		flags = append(flags, "-thirdperson")
+	}
+	if hacks.JumpHeight >= 0 {
+		flags = append(flags, fmt.Sprintf("jumpheight=%.2f", float64(hacks.JumpHeight)/32.0))
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// Server MOTD with the flags for the world's hack settings, trimming the MOTD to keep the flags
+func (server *ClassicServer) MOTD(world *World) string {
+	flags := world.Hacks.MOTDFlags()
+	if flags == "" {
+		return server.Settings.MOTD
+	}
+
+	motd := server.Settings.MOTD
+	if len(motd)+len(flags)+1 > 64 {
+		motd = motd[:64-len(flags)-1]
+	}
+
+	return motd + " " + flags
+}
+
+func (player *Player) SendHackControl(world *World) {
+	if !player.HasExtension(packets.EXT_HACK_CONTROL) {
+		return
+	}
+
+	player.Write(packets.NewDownstreamHackControl(
+		world.Hacks.Flying,
+		world.Hacks.NoClip,
+		world.Hacks.Speeding,
+		world.Hacks.SpawnControl,
+		world.Hacks.ThirdPerson,
+		world.Hacks.JumpHeight,
+	))
+}
@@ -0,0 +1,201 @@
+package classic
+
+import (
+	. "classicserver/classic/constants"
+	"classicserver/classic/packets"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const HOTKEYS_FILENAME = "hotkeys.txt"
+
+const (
+	HOTKEY_RANK_ALL = "all"
+	HOTKEY_RANK_OP  = "op"
+)
+
+// LWJGL key codes, as used by TextHotKey
+var keyCodes = map[string]int32{
+	"1": 2, "2": 3, "3": 4, "4": 5, "5": 6, "6": 7, "7": 8, "8": 9, "9": 10, "0": 11,
+	"Q": 16, "W": 17, "E": 18, "R": 19, "T": 20, "Y": 21, "U": 22, "I": 23, "O": 24, "P": 25,
+	"A": 30, "S": 31, "D": 32, "F": 33, "G": 34, "H": 35, "J": 36, "K": 37, "L": 38,
+	"Z": 44, "X": 45, "C": 46, "V": 47, "B": 48, "N": 49, "M": 50,
+	"F1": 59, "F2": 60, "F3": 61, "F4": 62, "F5": 63, "F6": 64,
+	"F7": 65, "F8": 66, "F9": 67, "F10": 68, "F11": 87, "F12": 88,
+	"NUMPAD7": 71, "NUMPAD8": 72, "NUMPAD9": 73, "NUMPAD4": 75, "NUMPAD5": 76,
+	"NUMPAD6": 77, "NUMPAD1": 79, "NUMPAD2": 80, "NUMPAD3": 81, "NUMPAD0": 82,
+}
+
+type HotKey struct {
+	Rank    string
+	Key     string
+	KeyCode int32
+	KeyMods uint8
+	Label   string
+	Action  string
+}
+
+func parseKeyMods(value string) (uint8, error) {
+	mods := uint8(0)
+	if strings.ToLower(value) == "none" {
+		return mods, nil
+	}
+
+	for _, mod := range strings.Split(strings.ToLower(value), "+") {
+		switch mod {
+		case "ctrl":
+			mods |= packets.KEY_MOD_CTRL
+		case "shift":
+			mods |= packets.KEY_MOD_SHIFT
+		case "alt":
+			mods |= packets.KEY_MOD_ALT
+		default:
+			return 0, fmt.Errorf("Unknown key modifier \"%s\"", mod)
+		}
+	}
+
+	return mods, nil
+}
+
+func formatKeyMods(mods uint8) string {
+	names := []string{}
+	if mods&packets.KEY_MOD_CTRL != 0 {
+		names = append(names, "ctrl")
+	}
+	if mods&packets.KEY_MOD_SHIFT != 0 {
+		names = append(names, "shift")
+	}
+	if mods&packets.KEY_MOD_ALT != 0 {
+		names = append(names, "alt")
+	}
+
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, "+")
+}
+
+// Parses a "rank,key,mods,label,action" line, where key is a name from keyCodes or an LWJGL key code
+func parseHotKey(line string) (*HotKey, error) {
+	segments := strings.SplitN(line, ",", 5)
+	if len(segments) != 5 {
+		return nil, fmt.Errorf("Expected rank,key,mods,label,action")
+	}
+
+	hotKey := HotKey{
+		Rank:   strings.ToLower(strings.TrimSpace(segments[0])),
+		Key:    strings.ToUpper(strings.TrimSpace(segments[1])),
+		Label:  strings.TrimSpace(segments[3]),
+		Action: strings.TrimSpace(segments[4]),
+	}
+
+	if hotKey.Rank != HOTKEY_RANK_ALL && hotKey.Rank != HOTKEY_RANK_OP {
+		return nil, fmt.Errorf("Unknown rank \"%s\"", hotKey.Rank)
+	}
+
+	if code, ok := keyCodes[hotKey.Key]; ok {
+		hotKey.KeyCode = code
+	} else if parsed, err := strconv.ParseInt(hotKey.Key, 10, 32); err == nil {
+		hotKey.KeyCode = int32(parsed)
+	} else {
+		return nil, fmt.Errorf("Unknown key \"%s\"", hotKey.Key)
+	}
+
+	mods, err := parseKeyMods(strings.TrimSpace(segments[2]))
+	if err != nil {
+		return nil, err
+	}
+	hotKey.KeyMods = mods
+
+	if hotKey.Action == "" {
+		return nil, fmt.Errorf("Missing action")
+	}
+
+	return &hotKey, nil
+}
+
+func CreateDefaultHotKeys() []HotKey {
+	return []HotKey{
+		{
+			Rank:    HOTKEY_RANK_OP,
+			Key:     "F5",
+			KeyCode: keyCodes["F5"],
+			Label:   "Save World",
+			Action:  "/saveworld",
+		},
+	}
+}
+
+func LoadHotKeys() ([]HotKey, error) {
+	if _, err := os.Stat(HOTKEYS_FILENAME); err != nil {
+		if os.IsNotExist(err) {
+			log.Println("Creating new hotkeys.txt...")
+			hotKeys := CreateDefaultHotKeys()
+			return hotKeys, SaveHotKeys(hotKeys)
+		} else {
+			return nil, err
+		}
+	}
+
+	contents, err := os.ReadFile(HOTKEYS_FILENAME)
+	if err != nil {
+		return nil, err
+	}
+
+	hotKeys := []HotKey{}
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		hotKey, err := parseHotKey(line)
+		if err != nil {
+			log.Println(err)
+			log.Printf("Unable to interpret hotkey \"%s\"\n", line)
+			continue
+		}
+
+		hotKeys = append(hotKeys, *hotKey)
+	}
+
+	return hotKeys, nil
+}
+
+func SaveHotKeys(hotKeys []HotKey) error {
+	var sb strings.Builder
+	sb.WriteString("# rank,key,mods,label,action - rank is all or op, mods is none or e.g. ctrl+shift\n")
+	for _, hotKey := range hotKeys {
+		sb.WriteString(fmt.Sprintf(
+			"%s,%s,%s,%s,%s\n",
+			hotKey.Rank,
+			hotKey.Key,
+			formatKeyMods(hotKey.KeyMods),
+			hotKey.Label,
+			hotKey.Action,
+		))
+	}
+
+	err := os.WriteFile(HOTKEYS_FILENAME, []byte(sb.String()), 0644)
+	return err
+}
+
+// Binds the hotkeys available to the player's rank, unbinding any they no longer have access to
+func (player *Player) SendHotKeys() {
+	if !player.HasExtension(packets.EXT_TEXT_HOTKEY) {
+		return
+	}
+
+	for _, hotKey := range player.Server.HotKeys {
+		action := hotKey.Action + "\n"
+		if hotKey.Rank == HOTKEY_RANK_OP && player.Mode != MODE_OP {
+			action = ""
+		}
+
+		player.Write(packets.NewDownstreamSetTextHotKey(hotKey.Label, action, hotKey.KeyCode, hotKey.KeyMods))
+	}
+}
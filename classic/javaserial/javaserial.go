@@ -0,0 +1,614 @@
+// Package javaserial reads Java object serialization streams into plain values, without running or
+// instantiating any Java classes. Only the parts of the protocol needed to walk saved game state are
+// supported; class data written by custom writeExternal methods without block data is rejected.
+package javaserial
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	STREAM_MAGIC   uint16 = 0xACED
+	STREAM_VERSION uint16 = 5
+)
+
+const (
+	TC_NULL           uint8 = 0x70
+	TC_REFERENCE      uint8 = 0x71
+	TC_CLASSDESC      uint8 = 0x72
+	TC_OBJECT         uint8 = 0x73
+	TC_STRING         uint8 = 0x74
+	TC_ARRAY          uint8 = 0x75
+	TC_CLASS          uint8 = 0x76
+	TC_BLOCKDATA      uint8 = 0x77
+	TC_ENDBLOCKDATA   uint8 = 0x78
+	TC_RESET          uint8 = 0x79
+	TC_BLOCKDATALONG  uint8 = 0x7A
+	TC_EXCEPTION      uint8 = 0x7B
+	TC_LONGSTRING     uint8 = 0x7C
+	TC_PROXYCLASSDESC uint8 = 0x7D
+	TC_ENUM           uint8 = 0x7E
+)
+
+const BASE_HANDLE uint32 = 0x7E0000
+
+// Class description flags
+const (
+	SC_WRITE_METHOD   uint8 = 0x01
+	SC_SERIALIZABLE   uint8 = 0x02
+	SC_EXTERNALIZABLE uint8 = 0x04
+	SC_BLOCK_DATA     uint8 = 0x08
+	SC_ENUM           uint8 = 0x10
+)
+
+// Limits that stop a malicious stream from exhausting memory or the stack
+const (
+	MAX_DEPTH        = 256
+	MAX_HANDLES      = 1 << 20
+	MAX_ARRAY_LENGTH = 1 << 28
+)
+
+type Field struct {
+	Type      uint8 // Type code, such as 'I' for int or 'L' for an object
+	Name      string
+	ClassName string // For object and array fields
+}
+
+type ClassDesc struct {
+	Name       string
+	Serial     int64
+	Flags      uint8
+	Fields     []Field
+	SuperClass *ClassDesc
+}
+
+// Values are nil, bool, int8, uint16 (char), int16, int32, int64, float32, float64, string, []byte, []int32,
+// []interface{} for other arrays, *Object, *Enum, *ClassDesc, or BlockData
+type Object struct {
+	Class  *ClassDesc
+	Fields map[string]interface{}
+
+	// Objects and block data written by custom writeObject methods, after the fields
+	Annotations []interface{}
+}
+
+type Enum struct {
+	Class    *ClassDesc
+	Constant string
+}
+
+type BlockData []byte
+
+type reader struct {
+	r       *bufio.Reader
+	handles []interface{}
+	depth   int
+}
+
+// Reads the first object of a serialization stream
+func ReadObject(r io.Reader) (interface{}, error) {
+	sr := &reader{r: bufio.NewReader(r)}
+
+	magic, err := sr.readUint16()
+	if err != nil {
+		return nil, err
+	}
+	version, err := sr.readUint16()
+	if err != nil {
+		return nil, err
+	}
+	if magic != STREAM_MAGIC || version != STREAM_VERSION {
+		return nil, fmt.Errorf("Not a Java serialization stream")
+	}
+
+	return sr.readContent()
+}
+
+func (object *Object) Int(name string) (int32, bool) {
+	value, ok := object.Fields[name].(int32)
+	return value, ok
+}
+
+func (object *Object) Float(name string) (float32, bool) {
+	value, ok := object.Fields[name].(float32)
+	return value, ok
+}
+
+func (object *Object) Bytes(name string) ([]byte, bool) {
+	value, ok := object.Fields[name].([]byte)
+	return value, ok
+}
+
+func (object *Object) String(name string) (string, bool) {
+	value, ok := object.Fields[name].(string)
+	return value, ok
+}
+
+func (sr *reader) readFull(n int) ([]byte, error) {
+	buffer := make([]byte, n)
+	_, err := io.ReadFull(sr.r, buffer)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buffer, err
+}
+
+func (sr *reader) readUint8() (uint8, error) {
+	value, err := sr.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return value, err
+}
+
+func (sr *reader) readUint16() (uint16, error) {
+	buffer, err := sr.readFull(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(buffer), nil
+}
+
+func (sr *reader) readUint32() (uint32, error) {
+	buffer, err := sr.readFull(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buffer), nil
+}
+
+func (sr *reader) readUint64() (uint64, error) {
+	buffer, err := sr.readFull(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buffer), nil
+}
+
+// Modified UTF-8 is read as-is, which only differs from UTF-8 for NUL and supplementary characters
+func (sr *reader) readUTF() (string, error) {
+	length, err := sr.readUint16()
+	if err != nil {
+		return "", err
+	}
+	buffer, err := sr.readFull(int(length))
+	return string(buffer), err
+}
+
+func (sr *reader) readLongUTF() (string, error) {
+	length, err := sr.readUint64()
+	if err != nil {
+		return "", err
+	}
+	if length > MAX_ARRAY_LENGTH {
+		return "", fmt.Errorf("String of %d bytes is too long", length)
+	}
+	buffer, err := sr.readArray(int(length), 1)
+	return string(buffer), err
+}
+
+// Reads an array without trusting the length to allocate it, so truncated streams fail before using the memory
+func (sr *reader) readArray(length int, size int) ([]byte, error) {
+	buffer, err := io.ReadAll(io.LimitReader(sr.r, int64(length)*int64(size)))
+	if err != nil {
+		return nil, err
+	}
+	if len(buffer) != length*size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buffer, nil
+}
+
+func (sr *reader) newHandle(value interface{}) (int, error) {
+	if len(sr.handles) >= MAX_HANDLES {
+		return 0, fmt.Errorf("Stream has more than %d objects", MAX_HANDLES)
+	}
+	sr.handles = append(sr.handles, value)
+	return len(sr.handles) - 1, nil
+}
+
+func (sr *reader) readReference() (interface{}, error) {
+	handle, err := sr.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if handle < BASE_HANDLE || int(handle-BASE_HANDLE) >= len(sr.handles) {
+		return nil, fmt.Errorf("Invalid reference 0x%x", handle)
+	}
+	return sr.handles[handle-BASE_HANDLE], nil
+}
+
+func (sr *reader) readContent() (interface{}, error) {
+	tc, err := sr.readUint8()
+	if err != nil {
+		return nil, err
+	}
+	return sr.readContentOf(tc)
+}
+
+func (sr *reader) readContentOf(tc uint8) (interface{}, error) {
+	sr.depth++
+	defer func() { sr.depth-- }()
+	if sr.depth > MAX_DEPTH {
+		return nil, fmt.Errorf("Objects nested deeper than %d", MAX_DEPTH)
+	}
+
+	switch tc {
+
+	case TC_NULL:
+		return nil, nil
+
+	case TC_REFERENCE:
+		return sr.readReference()
+
+	case TC_OBJECT:
+		return sr.readNewObject()
+
+	case TC_STRING:
+		value, err := sr.readUTF()
+		if err != nil {
+			return nil, err
+		}
+		_, err = sr.newHandle(value)
+		return value, err
+
+	case TC_LONGSTRING:
+		value, err := sr.readLongUTF()
+		if err != nil {
+			return nil, err
+		}
+		_, err = sr.newHandle(value)
+		return value, err
+
+	case TC_ARRAY:
+		return sr.readNewArray()
+
+	case TC_CLASS:
+		class, err := sr.readClassDesc()
+		if err != nil {
+			return nil, err
+		}
+		_, err = sr.newHandle(class)
+		return class, err
+
+	case TC_CLASSDESC, TC_PROXYCLASSDESC:
+		return sr.readClassDescOf(tc)
+
+	case TC_ENUM:
+		class, err := sr.readClassDesc()
+		if err != nil {
+			return nil, err
+		}
+		enum := &Enum{Class: class}
+		if _, err := sr.newHandle(enum); err != nil {
+			return nil, err
+		}
+		constant, err := sr.readContent()
+		if err != nil {
+			return nil, err
+		}
+		if enum.Constant, _ = constant.(string); enum.Constant == "" {
+			return nil, errors.New("Enum constant is not a string")
+		}
+		return enum, nil
+
+	case TC_BLOCKDATA:
+		length, err := sr.readUint8()
+		if err != nil {
+			return nil, err
+		}
+		data, err := sr.readFull(int(length))
+		return BlockData(data), err
+
+	case TC_BLOCKDATALONG:
+		length, err := sr.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		if length > MAX_ARRAY_LENGTH {
+			return nil, fmt.Errorf("Block data of %d bytes is too long", length)
+		}
+		data, err := sr.readArray(int(length), 1)
+		return BlockData(data), err
+
+	case TC_RESET:
+		sr.handles = sr.handles[:0]
+		return sr.readContent()
+
+	case TC_EXCEPTION:
+		return nil, errors.New("Stream was aborted by an exception while writing")
+
+	default:
+		return nil, fmt.Errorf("Unknown type code 0x%x", tc)
+
+	}
+}
+
+// Reads a class description, which may be null or a reference to an earlier one
+func (sr *reader) readClassDesc() (*ClassDesc, error) {
+	tc, err := sr.readUint8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tc {
+	case TC_NULL:
+		return nil, nil
+	case TC_REFERENCE:
+		value, err := sr.readReference()
+		if err != nil {
+			return nil, err
+		}
+		class, ok := value.(*ClassDesc)
+		if !ok {
+			return nil, errors.New("Reference is not a class description")
+		}
+		return class, nil
+	case TC_CLASSDESC, TC_PROXYCLASSDESC:
+		return sr.readClassDescOf(tc)
+	default:
+		return nil, fmt.Errorf("Expected class description, got type code 0x%x", tc)
+	}
+}
+
+func (sr *reader) readClassDescOf(tc uint8) (*ClassDesc, error) {
+	class := &ClassDesc{}
+
+	if tc == TC_PROXYCLASSDESC {
+		class.Flags = SC_SERIALIZABLE
+		if _, err := sr.newHandle(class); err != nil {
+			return nil, err
+		}
+		count, err := sr.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		if count > math.MaxUint16 {
+			return nil, fmt.Errorf("Proxy class with %d interfaces", count)
+		}
+		for i := uint32(0); i < count; i++ {
+			if _, err := sr.readUTF(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		var err error
+		if class.Name, err = sr.readUTF(); err != nil {
+			return nil, err
+		}
+		serial, err := sr.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		class.Serial = int64(serial)
+		if _, err := sr.newHandle(class); err != nil {
+			return nil, err
+		}
+		if class.Flags, err = sr.readUint8(); err != nil {
+			return nil, err
+		}
+
+		count, err := sr.readUint16()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint16(0); i < count; i++ {
+			field := Field{}
+			if field.Type, err = sr.readUint8(); err != nil {
+				return nil, err
+			}
+			if field.Name, err = sr.readUTF(); err != nil {
+				return nil, err
+			}
+			if field.Type == 'L' || field.Type == '[' {
+				className, err := sr.readContent()
+				if err != nil {
+					return nil, err
+				}
+				if field.ClassName, _ = className.(string); field.ClassName == "" {
+					return nil, fmt.Errorf("Field %s has no class name", field.Name)
+				}
+			}
+			class.Fields = append(class.Fields, field)
+		}
+	}
+
+	if _, err := sr.readAnnotations(); err != nil {
+		return nil, err
+	}
+
+	superClass, err := sr.readClassDesc()
+	if err != nil {
+		return nil, err
+	}
+	class.SuperClass = superClass
+
+	return class, nil
+}
+
+// Reads contents up to the end of block data marker
+func (sr *reader) readAnnotations() ([]interface{}, error) {
+	annotations := []interface{}{}
+	for {
+		tc, err := sr.readUint8()
+		if err != nil {
+			return nil, err
+		}
+		if tc == TC_ENDBLOCKDATA {
+			return annotations, nil
+		}
+
+		value, err := sr.readContentOf(tc)
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, value)
+	}
+}
+
+func (sr *reader) readNewObject() (*Object, error) {
+	class, err := sr.readClassDesc()
+	if err != nil {
+		return nil, err
+	}
+	if class == nil {
+		return nil, errors.New("Object has no class")
+	}
+
+	object := &Object{
+		Class:  class,
+		Fields: make(map[string]interface{}),
+	}
+	if _, err := sr.newHandle(object); err != nil {
+		return nil, err
+	}
+
+	// Class data is written from the topmost superclass down
+	hierarchy := []*ClassDesc{}
+	for c := class; c != nil; c = c.SuperClass {
+		hierarchy = append([]*ClassDesc{c}, hierarchy...)
+		if len(hierarchy) > MAX_DEPTH {
+			return nil, errors.New("Class hierarchy is too deep")
+		}
+	}
+
+	for _, c := range hierarchy {
+		if c.Flags&SC_EXTERNALIZABLE != 0 {
+			if c.Flags&SC_BLOCK_DATA == 0 {
+				return nil, fmt.Errorf("Externalizable class %s without block data is unsupported", c.Name)
+			}
+			annotations, err := sr.readAnnotations()
+			if err != nil {
+				return nil, err
+			}
+			object.Annotations = append(object.Annotations, annotations...)
+			continue
+		}
+
+		for _, field := range c.Fields {
+			value, err := sr.readFieldValue(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", c.Name, field.Name, err)
+			}
+			object.Fields[field.Name] = value
+		}
+
+		if c.Flags&SC_WRITE_METHOD != 0 {
+			annotations, err := sr.readAnnotations()
+			if err != nil {
+				return nil, err
+			}
+			object.Annotations = append(object.Annotations, annotations...)
+		}
+	}
+
+	return object, nil
+}
+
+func (sr *reader) readFieldValue(fieldType uint8) (interface{}, error) {
+	switch fieldType {
+	case 'B':
+		value, err := sr.readUint8()
+		return int8(value), err
+	case 'C':
+		return sr.readUint16()
+	case 'D':
+		value, err := sr.readUint64()
+		return math.Float64frombits(value), err
+	case 'F':
+		value, err := sr.readUint32()
+		return math.Float32frombits(value), err
+	case 'I':
+		value, err := sr.readUint32()
+		return int32(value), err
+	case 'J':
+		value, err := sr.readUint64()
+		return int64(value), err
+	case 'S':
+		value, err := sr.readUint16()
+		return int16(value), err
+	case 'Z':
+		value, err := sr.readUint8()
+		return value != 0, err
+	case 'L', '[':
+		return sr.readContent()
+	default:
+		return nil, fmt.Errorf("Unknown field type '%c'", fieldType)
+	}
+}
+
+var primitiveSizes = map[uint8]int{
+	'B': 1, 'Z': 1, 'C': 2, 'S': 2, 'F': 4, 'I': 4, 'D': 8, 'J': 8,
+}
+
+func (sr *reader) readNewArray() (interface{}, error) {
+	class, err := sr.readClassDesc()
+	if err != nil {
+		return nil, err
+	}
+	if class == nil || len(class.Name) < 2 || class.Name[0] != '[' {
+		return nil, errors.New("Array has no array class")
+	}
+
+	handle, err := sr.newHandle(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := sr.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if length > MAX_ARRAY_LENGTH {
+		return nil, fmt.Errorf("Array of %d elements is too long", length)
+	}
+
+	elementType := class.Name[1]
+	var array interface{}
+
+	if size, ok := primitiveSizes[elementType]; ok {
+		buffer, err := sr.readArray(int(length), size)
+		if err != nil {
+			return nil, err
+		}
+
+		switch elementType {
+		case 'B':
+			array = buffer
+		case 'I':
+			values := make([]int32, length)
+			for i := range values {
+				values[i] = int32(binary.BigEndian.Uint32(buffer[i*4:]))
+			}
+			array = values
+		default:
+			values := make([]interface{}, length)
+			element := &reader{r: bufio.NewReader(bytes.NewReader(buffer))}
+			for i := range values {
+				if values[i], err = element.readFieldValue(elementType); err != nil {
+					return nil, err
+				}
+			}
+			array = values
+		}
+	} else {
+		values := []interface{}{}
+		for i := uint32(0); i < length; i++ {
+			value, err := sr.readContent()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		array = values
+	}
+
+	sr.handles[handle] = array
+	return array, nil
+}
@@ -0,0 +1,181 @@
+package javaserial
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+// Builds serialization streams by hand, the way ObjectOutputStream writes them
+type stream struct {
+	bytes.Buffer
+}
+
+func newStream() *stream {
+	s := &stream{}
+	s.u16(STREAM_MAGIC)
+	s.u16(STREAM_VERSION)
+	return s
+}
+
+func (s *stream) u16(value uint16) {
+	binary.Write(s, binary.BigEndian, value)
+}
+
+func (s *stream) u32(value uint32) {
+	binary.Write(s, binary.BigEndian, value)
+}
+
+func (s *stream) utf(value string) {
+	s.u16(uint16(len(value)))
+	s.WriteString(value)
+}
+
+// Class description with no annotations or superclass
+func (s *stream) classDesc(name string, fields ...Field) {
+	s.WriteByte(TC_CLASSDESC)
+	s.utf(name)
+	s.Write(make([]byte, 8))
+	s.WriteByte(SC_SERIALIZABLE)
+	s.u16(uint16(len(fields)))
+	for _, field := range fields {
+		s.WriteByte(field.Type)
+		s.utf(field.Name)
+		if field.Type == 'L' || field.Type == '[' {
+			s.WriteByte(TC_STRING)
+			s.utf(field.ClassName)
+		}
+	}
+	s.WriteByte(TC_ENDBLOCKDATA)
+	s.WriteByte(TC_NULL)
+}
+
+func (s *stream) byteArray(values []byte) {
+	s.WriteByte(TC_ARRAY)
+	s.classDesc("[B")
+	s.u32(uint32(len(values)))
+	s.Write(values)
+}
+
+func levelStream() []byte {
+	s := newStream()
+	s.WriteByte(TC_OBJECT)
+	s.classDesc("Level",
+		Field{Type: 'I', Name: "width"},
+		Field{Type: 'F', Name: "rotSpawn"},
+		Field{Type: 'Z', Name: "creativeMode"},
+		Field{Type: '[', Name: "blocks", ClassName: "[B"},
+		Field{Type: 'L', Name: "name", ClassName: "Ljava/lang/String;"},
+	)
+	s.u32(16)
+	s.u32(math.Float32bits(90))
+	s.WriteByte(1)
+	s.byteArray([]byte{1, 2, 3, 4})
+	s.WriteByte(TC_STRING)
+	s.utf("A level")
+	return s.Bytes()
+}
+
+func TestReadObject(t *testing.T) {
+	value, err := ReadObject(bytes.NewReader(levelStream()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	level, ok := value.(*Object)
+	if !ok || level.Class.Name != "Level" {
+		t.Fatalf("Expected a Level object, got %#v", value)
+	}
+	if width, _ := level.Int("width"); width != 16 {
+		t.Errorf("width = %d, expected 16", width)
+	}
+	if rotSpawn, _ := level.Float("rotSpawn"); rotSpawn != 90 {
+		t.Errorf("rotSpawn = %f, expected 90", rotSpawn)
+	}
+	if creative, _ := level.Fields["creativeMode"].(bool); !creative {
+		t.Errorf("creativeMode = %v, expected true", level.Fields["creativeMode"])
+	}
+	if blocks, _ := level.Bytes("blocks"); !bytes.Equal(blocks, []byte{1, 2, 3, 4}) {
+		t.Errorf("blocks = %v, expected [1 2 3 4]", blocks)
+	}
+	if name, _ := level.String("name"); name != "A level" {
+		t.Errorf("name = %q, expected \"A level\"", name)
+	}
+}
+
+func TestReadObjectReference(t *testing.T) {
+	s := newStream()
+	s.WriteByte(TC_OBJECT)
+	s.classDesc("Pair",
+		Field{Type: 'L', Name: "first", ClassName: "Ljava/lang/String;"},
+		Field{Type: 'L', Name: "second", ClassName: "Ljava/lang/String;"},
+	)
+	s.WriteByte(TC_STRING)
+	s.utf("shared")
+	// Handles 0 to 3 are the class, the two class names of its fields and the object
+	s.WriteByte(TC_REFERENCE)
+	s.u32(BASE_HANDLE + 4)
+
+	value, err := ReadObject(bytes.NewReader(s.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair := value.(*Object)
+	if second, _ := pair.String("second"); second != "shared" {
+		t.Errorf("second = %q, expected \"shared\"", second)
+	}
+}
+
+func TestReadObjectTruncated(t *testing.T) {
+	data := levelStream()
+	for length := 0; length < len(data); length++ {
+		if _, err := ReadObject(bytes.NewReader(data[:length])); err == nil {
+			t.Fatalf("Stream truncated to %d of %d bytes was read without an error", length, len(data))
+		}
+	}
+}
+
+func TestReadObjectOversizedArray(t *testing.T) {
+	s := newStream()
+	s.WriteByte(TC_ARRAY)
+	s.classDesc("[B")
+	s.u32(MAX_ARRAY_LENGTH + 1)
+	if _, err := ReadObject(bytes.NewReader(s.Bytes())); err == nil {
+		t.Error("Array longer than the limit was read without an error")
+	}
+
+	// Within the limit, but longer than the stream
+	s = newStream()
+	s.WriteByte(TC_ARRAY)
+	s.classDesc("[I")
+	s.u32(MAX_ARRAY_LENGTH)
+	s.Write(make([]byte, 16))
+	if _, err := ReadObject(bytes.NewReader(s.Bytes())); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF for an array longer than the stream, got %v", err)
+	}
+}
+
+func TestReadObjectInvalid(t *testing.T) {
+	if _, err := ReadObject(bytes.NewReader([]byte{0x1f, 0x8b, 0, 5, TC_NULL})); err == nil {
+		t.Error("Stream without the magic number was read without an error")
+	}
+
+	s := newStream()
+	s.WriteByte(TC_REFERENCE)
+	s.u32(BASE_HANDLE)
+	if _, err := ReadObject(bytes.NewReader(s.Bytes())); err == nil {
+		t.Error("Reference to a missing handle was read without an error")
+	}
+
+	s = newStream()
+	for i := 0; i <= MAX_DEPTH; i++ {
+		s.WriteByte(TC_RESET)
+	}
+	s.WriteByte(TC_NULL)
+	if _, err := ReadObject(bytes.NewReader(s.Bytes())); err == nil {
+		t.Error("Stream nested deeper than the limit was read without an error")
+	}
+}
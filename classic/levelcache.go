@@ -0,0 +1,330 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"log"
+	"sync"
+	"time"
+)
+
+// Blocks in each independently compressed segment of the cached level data,
+// so a change only recompresses the segment it falls in
+const LEVEL_SEGMENT_BLOCKS = 64 * 1024
+
+// Block changes after which the cached level data is rebuilt, so the changes sent after it stay few
+const LEVEL_CACHE_REBUILD_CHANGES = 4096
+
+// Longest the cached level data goes without including a change
+const LEVEL_CACHE_REBUILD_INTERVAL = 30 * time.Second
+
+// Kinds of level data, which differ by whether the client supports FastMap and ExtendedBlocks
+const (
+	LEVEL_GZIP = iota
+	LEVEL_GZIP_EXT
+	LEVEL_FAST_MAP
+	LEVEL_FAST_MAP_EXT
+	LEVEL_VARIANTS
+)
+
+func levelVariant(fastMap bool, extBlocks bool) int {
+	variant := LEVEL_GZIP
+	if fastMap {
+		variant = LEVEL_FAST_MAP
+	}
+	if extBlocks {
+		variant++
+	}
+	return variant
+}
+
+// Header of a gzip stream with no name or modification time, as written by gzip.Writer
+var levelGzipHeader = []uint8{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 0xff}
+
+// Empty final DEFLATE block, ending a stream of flushed segments
+var levelDeflateEnd = []uint8{0x03, 0x00}
+
+// Compressed level data of a world, rebuilt in the background from the segments that changed.
+// Players joining get the cached data, then the changes made since it was built
+type levelCache struct {
+	started   bool         // Whether a build has been started; main loop only
+	changed   map[int]bool // Segments changed since the last build started; main loop only
+	lastBuild time.Time    // When the last build started; main loop only
+
+	segments levelSegments // Only used by the build in progress
+
+	lock     sync.Mutex
+	building bool
+	version  uint64 // Number of changes to the world the data includes
+	data     [LEVEL_VARIANTS][]uint8
+}
+
+// Compressed segments of level data, kept between builds so unchanged segments are reused
+type levelSegments struct {
+	blocks   []Block   // The world's blocks as of the last build
+	lower    [][]uint8 // Lower 8 bits of each segment's blocks
+	fallback [][]uint8 // Fallback blocks of each segment, for clients without ExtendedBlocks
+	upper    [][]uint8 // Upper bits of each segment's blocks
+	extended []bool    // Whether each segment holds extended blocks
+}
+
+// Cached level data of the variant, with the number of changes it includes, or nil if none has been built
+func (world *World) cachedLevelData(variant int) ([]uint8, uint64) {
+	cache := &world.level
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return cache.data[variant], cache.version
+}
+
+// Notes a change to the block at the index, for the journal and the next build
+func (world *World) recordBlockChange(index int32, change BlockChange) {
+	world.changes = append(world.changes, change)
+
+	cache := &world.level
+	if cache.changed == nil {
+		cache.changed = make(map[int]bool)
+	}
+	cache.changed[int(index)/LEVEL_SEGMENT_BLOCKS] = true
+}
+
+// Number of changes made to the world's blocks while it has been loaded
+func (world *World) changeCount() uint64 {
+	return world.changesStart + uint64(len(world.changes))
+}
+
+// Changes made since the given change number, keeping only the last at each position
+func (world *World) changesSince(number uint64) []BlockChange {
+	if number < world.changesStart {
+		number = world.changesStart
+	}
+
+	changes := []BlockChange{}
+	indices := make(map[int32]int)
+	for _, change := range world.changes[number-world.changesStart:] {
+		index := world.BlockIndex(change.X, change.Y, change.Z)
+		if i, ok := indices[index]; ok {
+			changes[i] = change
+			continue
+		}
+		indices[index] = len(changes)
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// Drops journalled changes the cached level data includes, and starts a build if enough have been made since
+func (world *World) updateLevelCache() {
+	cache := &world.level
+	cache.lock.Lock()
+	building := cache.building
+	version := cache.version
+	cache.lock.Unlock()
+
+	if version > world.changesStart {
+		world.changes = append([]BlockChange(nil), world.changes[version-world.changesStart:]...)
+		world.changesStart = version
+	}
+
+	if building {
+		return
+	}
+
+	if cache.started {
+		pending := world.changeCount() - version
+		if pending == 0 || (pending < LEVEL_CACHE_REBUILD_CHANGES && time.Since(cache.lastBuild) < LEVEL_CACHE_REBUILD_INTERVAL) {
+			return
+		}
+	}
+
+	world.buildLevelCache()
+}
+
+// Copies the changed segments and compresses them in the background
+func (world *World) buildLevelCache() {
+	cache := &world.level
+	changed := make(map[int][]Block)
+	copySegment := func(segment int) {
+		start := segment * LEVEL_SEGMENT_BLOCKS
+		end := start + LEVEL_SEGMENT_BLOCKS
+		if end > len(world.Blocks) {
+			end = len(world.Blocks)
+		}
+		changed[segment] = append([]Block(nil), world.Blocks[start:end]...)
+	}
+
+	if !cache.started {
+		for start := 0; start < len(world.Blocks); start += LEVEL_SEGMENT_BLOCKS {
+			copySegment(start / LEVEL_SEGMENT_BLOCKS)
+		}
+	} else {
+		for segment := range cache.changed {
+			copySegment(segment)
+		}
+	}
+
+	cache.started = true
+	cache.changed = nil
+	cache.lastBuild = time.Now()
+	version := world.changeCount()
+	volume := len(world.Blocks)
+
+	cache.lock.Lock()
+	cache.building = true
+	cache.lock.Unlock()
+
+	go func() {
+		data, err := cache.segments.update(changed, volume)
+		if err != nil {
+			log.Println(err)
+		}
+
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+		if err == nil {
+			cache.data = data
+			cache.version = version
+		}
+		cache.building = false
+	}()
+}
+
+// Applies and compresses the changed segments, then puts together the level data of each variant
+func (segments *levelSegments) update(changed map[int][]Block, volume int) ([LEVEL_VARIANTS][]uint8, error) {
+	var data [LEVEL_VARIANTS][]uint8
+
+	if segments.blocks == nil {
+		count := (volume + LEVEL_SEGMENT_BLOCKS - 1) / LEVEL_SEGMENT_BLOCKS
+		segments.blocks = make([]Block, volume)
+		segments.lower = make([][]uint8, count)
+		segments.fallback = make([][]uint8, count)
+		segments.upper = make([][]uint8, count)
+		segments.extended = make([]bool, count)
+	}
+
+	lower := make([]uint8, LEVEL_SEGMENT_BLOCKS)
+	fallback := make([]uint8, LEVEL_SEGMENT_BLOCKS)
+	upper := make([]uint8, LEVEL_SEGMENT_BLOCKS)
+	for segment, blocks := range changed {
+		copy(segments.blocks[segment*LEVEL_SEGMENT_BLOCKS:], blocks)
+
+		extended := false
+		for i, block := range blocks {
+			lower[i] = uint8(block)
+			fallback[i] = FallbackBlock(block)
+			upper[i] = uint8(block >> 8)
+			if block > BLOCK_MAX_LEGACY {
+				extended = true
+			}
+		}
+		segments.extended[segment] = extended
+
+		var err error
+		if segments.lower[segment], err = compressLevelSegment(lower[:len(blocks)]); err != nil {
+			return data, err
+		}
+		if segments.fallback[segment], err = compressLevelSegment(fallback[:len(blocks)]); err != nil {
+			return data, err
+		}
+		if segments.upper[segment], err = compressLevelSegment(upper[:len(blocks)]); err != nil {
+			return data, err
+		}
+	}
+
+	extended := false
+	for _, segmentExtended := range segments.extended {
+		extended = extended || segmentExtended
+	}
+
+	extBlocks := append([][]uint8{}, segments.lower...)
+	if extended {
+		extBlocks = append(extBlocks, segments.upper...)
+	}
+
+	data[LEVEL_FAST_MAP] = joinLevelSegments(nil, segments.fallback)
+	data[LEVEL_FAST_MAP_EXT] = joinLevelSegments(nil, extBlocks)
+
+	levelSize := make([]uint8, 4)
+	binary.BigEndian.PutUint32(levelSize, uint32(volume))
+	prefix, err := compressLevelSegment(levelSize)
+	if err != nil {
+		return data, err
+	}
+
+	data[LEVEL_GZIP] = segments.gzipLevelData(prefix, segments.fallback, false, false)
+	data[LEVEL_GZIP_EXT] = segments.gzipLevelData(prefix, extBlocks, true, extended)
+
+	return data, nil
+}
+
+// Wraps the segments in a gzip stream, after the compressed volume prefix
+func (segments *levelSegments) gzipLevelData(prefix []uint8, compressed [][]uint8, extBlocks bool, withUpper bool) []uint8 {
+	data := append([]uint8{}, levelGzipHeader...)
+	data = joinLevelSegments(append(data, prefix...), compressed)
+
+	// The trailer needs the checksum of the uncompressed data
+	size := uint32(4)
+	hash := crc32.NewIEEE()
+	levelSize := make([]uint8, 4)
+	binary.BigEndian.PutUint32(levelSize, uint32(len(segments.blocks)))
+	hash.Write(levelSize)
+
+	buffer := make([]uint8, LEVEL_SEGMENT_BLOCKS)
+	writeBlocks := func(byteOf func(block Block) uint8) {
+		for start := 0; start < len(segments.blocks); start += LEVEL_SEGMENT_BLOCKS {
+			blocks := segments.blocks[start:]
+			if len(blocks) > LEVEL_SEGMENT_BLOCKS {
+				blocks = blocks[:LEVEL_SEGMENT_BLOCKS]
+			}
+			for i, block := range blocks {
+				buffer[i] = byteOf(block)
+			}
+			hash.Write(buffer[:len(blocks)])
+			size += uint32(len(blocks))
+		}
+	}
+
+	if extBlocks {
+		writeBlocks(func(block Block) uint8 { return uint8(block) })
+	} else {
+		writeBlocks(FallbackBlock)
+	}
+	if withUpper {
+		writeBlocks(func(block Block) uint8 { return uint8(block >> 8) })
+	}
+
+	data = binary.LittleEndian.AppendUint32(data, hash.Sum32())
+	return binary.LittleEndian.AppendUint32(data, size)
+}
+
+// Compresses a segment as DEFLATE blocks that don't end the stream, so segments can be joined
+func compressLevelSegment(data []uint8) ([]uint8, error) {
+	var b bytes.Buffer
+	fl, err := flate.NewWriter(&b, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fl.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := fl.Flush(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Appends the segments and the end of the DEFLATE stream
+func joinLevelSegments(data []uint8, segments [][]uint8) []uint8 {
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+
+	return append(data, levelDeflateEnd...)
+}
@@ -0,0 +1,122 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+)
+
+// Builds the cached level data and waits for the build to finish
+func buildLevelCacheNow(t *testing.T, world *World) {
+	t.Helper()
+
+	world.buildLevelCache()
+	for start := time.Now(); ; time.Sleep(time.Millisecond) {
+		world.level.lock.Lock()
+		building := world.level.building
+		world.level.lock.Unlock()
+		if !building {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("Level cache build didn't finish")
+		}
+	}
+
+	if _, version := world.cachedLevelData(LEVEL_GZIP); version != world.changeCount() {
+		t.Fatalf("Level cache includes %d changes, expected %d", version, world.changeCount())
+	}
+}
+
+func inflate(t *testing.T, data []uint8, gzipped bool) []uint8 {
+	t.Helper()
+
+	var r io.Reader = flate.NewReader(bytes.NewReader(data))
+	if gzipped {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+
+	// Reading to the end also checks the gzip trailer
+	inflated, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inflated
+}
+
+// Fails the test unless each variant of the cached level data inflates to the same bytes as compressing the whole level
+func assertLevelCacheMatches(t *testing.T, world *World) {
+	t.Helper()
+
+	for _, fastMap := range []bool{false, true} {
+		for _, extBlocks := range []bool{false, true} {
+			cached, _ := world.cachedLevelData(levelVariant(fastMap, extBlocks))
+
+			var expected []uint8
+			var err error
+			if fastMap {
+				expected, err = world.fastMapLevelData(extBlocks)
+			} else {
+				expected, err = world.gzipLevelData(extBlocks)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(inflate(t, cached, !fastMap), inflate(t, expected, !fastMap)) {
+				t.Errorf("Cached level data with FastMap %v and ExtendedBlocks %v differs from the level", fastMap, extBlocks)
+			}
+		}
+	}
+}
+
+func TestLevelCache(t *testing.T) {
+	// Several segments, with the last one partial
+	world := newTestWorld(100, 20, 90, BLOCK_MAX_LEGACY)
+	buildLevelCacheNow(t, world)
+	assertLevelCacheMatches(t, world)
+
+	// Changes in a few segments, with the last making the world use extended blocks
+	world.SetBlock(0, 0, 0, BLOCK_STONE)
+	world.SetBlock(50, 10, 45, BLOCK_AIR)
+	world.SetBlock(99, 19, 89, 0x2FF)
+	buildLevelCacheNow(t, world)
+	assertLevelCacheMatches(t, world)
+
+	// Then back to only legacy blocks
+	world.SetBlock(99, 19, 89, BLOCK_GLASS)
+	buildLevelCacheNow(t, world)
+	assertLevelCacheMatches(t, world)
+}
+
+func TestLevelCacheChangesSince(t *testing.T) {
+	world := newTestWorld(16, 16, 16, BLOCK_MAX_LEGACY)
+	world.SetBlock(1, 1, 1, BLOCK_STONE)
+	world.SetBlock(2, 2, 2, BLOCK_STONE)
+	world.SetBlock(1, 1, 1, BLOCK_GLASS)
+
+	changes := world.changesSince(0)
+	expected := []BlockChange{{1, 1, 1, BLOCK_GLASS}, {2, 2, 2, BLOCK_STONE}}
+	if len(changes) != len(expected) || changes[0] != expected[0] || changes[1] != expected[1] {
+		t.Errorf("Changes are %v, expected %v", changes, expected)
+	}
+
+	// Changes the cache includes are dropped from the journal
+	buildLevelCacheNow(t, world)
+	world.updateLevelCache()
+	world.SetBlock(3, 3, 3, BLOCK_STONE)
+	if changes := world.changesSince(3); len(changes) != 1 || changes[0] != (BlockChange{3, 3, 3, BLOCK_STONE}) {
+		t.Errorf("Changes since the cache are %v, expected only the last", changes)
+	}
+	if len(world.changes) != 1 {
+		t.Errorf("Journal holds %d changes, expected 1", len(world.changes))
+	}
+}
@@ -0,0 +1,308 @@
+package classic
+
+import (
+	"bufio"
+	"bytes"
+	. "classicserver/classic/constants"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"log"
+	"math"
+	"os"
+)
+
+const LVL_EXTENSION = ".lvl"
+
+// Written before the dimensions by MCGalaxy and later MCSharp versions; older files start with the width
+const LVL_MAGIC uint16 = 1874
+
+// Marks the section of 16x16x16 chunks holding the custom block IDs
+const LVL_CUSTOM_BLOCKS_MARKER uint8 = 0xBD
+
+const LVL_CHUNK_SIZE = 16
+
+// Placeholders in the block array for a custom block, whose ID is in the custom block section.
+// Each covers 256 IDs, starting from 0, 256 and 512
+var lvlCustomBlockMarkers = [3]uint8{163, 198, 199}
+
+// MCGalaxy only sends standard and CPE blocks without the custom block section
+const lvlMaxStandardBlock Block = 65
+
+// Physics blocks of MCGalaxy, as the block they appear as
+var lvlPhysicsBlocks = map[uint8]Block{
+	100: BLOCK_GLASS,
+	101: BLOCK_OBSIDIAN,
+	102: BLOCK_BRICKS,
+	103: BLOCK_STONE,
+	104: BLOCK_COBBLESTONE,
+	105: BLOCK_AIR,
+	106: BLOCK_WATER_STATIONARY,
+	107: BLOCK_LAVA_STATIONARY,
+	111: BLOCK_WOOD,
+	112: BLOCK_OBSIDIAN,
+	113: BLOCK_GLASS,
+	114: BLOCK_STONE,
+	115: BLOCK_LEAVES,
+	116: BLOCK_SAND,
+	117: BLOCK_PLANKS,
+	118: BLOCK_GRASS_BLOCK,
+	119: BLOCK_TNT,
+	120: BLOCK_SLAB,
+}
+
+func lvlCustomBlockBase(raw uint8) (Block, bool) {
+	for i, marker := range lvlCustomBlockMarkers {
+		if raw == marker {
+			return Block(i) << 8, true
+		}
+	}
+
+	return 0, false
+}
+
+// Custom blocks only go up to 767, so later blocks are saved as what legacy clients see instead
+func lvlSavedBlock(block Block) Block {
+	if int(block>>8) >= len(lvlCustomBlockMarkers) {
+		return Block(FallbackBlock(block))
+	}
+
+	return block
+}
+
+func ImportLvl(path string) (*World, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	reader := bufio.NewReader(gz)
+
+	readUshort := func() (uint16, error) {
+		buffer := make([]uint8, 2)
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			return 0, err
+		}
+		return binary.LittleEndian.Uint16(buffer), nil
+	}
+
+	header := make([]uint16, 6)
+	for i := range header {
+		if header[i], err = readUshort(); err != nil {
+			return nil, err
+		}
+
+		if i == 0 && header[0] == LVL_MAGIC {
+			if header[0], err = readUshort(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	width, length, height := header[0], header[1], header[2]
+	spawnX, spawnZ, spawnY := header[3], header[4], header[5]
+
+	// Rotation, followed by the visit and build permissions
+	rotation := make([]uint8, 4)
+	if _, err := io.ReadFull(reader, rotation); err != nil {
+		return nil, err
+	}
+
+	volume, err := importWorldVolume(int(width), int(height), int(length))
+	if err != nil {
+		return nil, err
+	}
+
+	world := &World{
+		SizeX:      int16(width),
+		SizeY:      int16(height),
+		SizeZ:      int16(length),
+		SpawnX:     float64(spawnX) + 0.5,
+		SpawnY:     float64(spawnY),
+		SpawnZ:     float64(spawnZ) + 0.5,
+		SpawnYaw:   rotation[0],
+		SpawnPitch: rotation[1],
+		Hacks:      DefaultHackSettings(),
+		Env:        DefaultEnvSettings(),
+		Blocks:     newBlocks(int16(width), int16(height), int16(length)),
+	}
+
+	raw := make([]uint8, volume)
+	if _, err := io.ReadFull(reader, raw); err != nil {
+		return nil, err
+	}
+
+	custom, err := readLvlCustomBlocks(reader, world)
+	if err != nil {
+		return nil, err
+	}
+
+	unknown := 0
+	for i := range world.Blocks {
+		block := Block(raw[i])
+		if base, ok := lvlCustomBlockBase(raw[i]); ok {
+			block = base | Block(custom[i])
+		} else if physics, ok := lvlPhysicsBlocks[raw[i]]; ok {
+			block = physics
+		} else if block > lvlMaxStandardBlock {
+			block = BLOCK_STONE
+			unknown++
+		}
+		world.Blocks[i] = block
+	}
+
+	if unknown > 0 {
+		log.Printf("Replaced %d unknown blocks in %s with stone\n", unknown, path)
+	}
+
+	return world, nil
+}
+
+// Reads the custom block section, if present, into an array indexed like the block array
+func readLvlCustomBlocks(reader *bufio.Reader, world *World) ([]uint8, error) {
+	custom := make([]uint8, world.Volume())
+
+	marker, err := reader.ReadByte()
+	if err == io.EOF || (err == nil && marker != LVL_CUSTOM_BLOCKS_MARKER) {
+		// Later sections hold physics and zones, which the server doesn't use
+		return custom, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	chunk := make([]uint8, LVL_CHUNK_SIZE*LVL_CHUNK_SIZE*LVL_CHUNK_SIZE)
+	for cy := int16(0); cy < world.SizeY; cy += LVL_CHUNK_SIZE {
+		for cz := int16(0); cz < world.SizeZ; cz += LVL_CHUNK_SIZE {
+			for cx := int16(0); cx < world.SizeX; cx += LVL_CHUNK_SIZE {
+				present, err := reader.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				if present != 1 {
+					continue
+				}
+
+				if _, err := io.ReadFull(reader, chunk); err != nil {
+					return nil, err
+				}
+
+				for y := int16(0); y < LVL_CHUNK_SIZE && cy+y < world.SizeY; y++ {
+					for z := int16(0); z < LVL_CHUNK_SIZE && cz+z < world.SizeZ; z++ {
+						for x := int16(0); x < LVL_CHUNK_SIZE && cx+x < world.SizeX; x++ {
+							custom[world.BlockIndex(cx+x, cy+y, cz+z)] = chunk[(y*LVL_CHUNK_SIZE+z)*LVL_CHUNK_SIZE+x]
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return custom, nil
+}
+
+func ExportLvl(world *World, path string) error {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+
+	writeUshort := func(value uint16) {
+		buffer := make([]uint8, 2)
+		binary.LittleEndian.PutUint16(buffer, value)
+		gz.Write(buffer)
+	}
+
+	writeUshort(LVL_MAGIC)
+	writeUshort(uint16(world.SizeX))
+	writeUshort(uint16(world.SizeZ))
+	writeUshort(uint16(world.SizeY))
+	writeUshort(uint16(math.Floor(world.SpawnX)))
+	writeUshort(uint16(math.Floor(world.SpawnZ)))
+	writeUshort(uint16(math.Floor(world.SpawnY)))
+	// Rotation, then visit and build permissions open to guests
+	gz.Write([]uint8{world.SpawnYaw, world.SpawnPitch, 0, 0})
+
+	hasCustom := false
+	unsupported := 0
+	raw := make([]uint8, len(world.Blocks))
+	for i, block := range world.Blocks {
+		if saved := lvlSavedBlock(block); saved != block {
+			block = saved
+			unsupported++
+		}
+
+		if block > lvlMaxStandardBlock {
+			raw[i] = lvlCustomBlockMarkers[block>>8]
+			hasCustom = true
+		} else {
+			raw[i] = uint8(block)
+		}
+	}
+	gz.Write(raw)
+
+	if unsupported > 0 {
+		log.Printf("Replaced %d blocks above %d with their fallback blocks when exporting %s\n", unsupported, len(lvlCustomBlockMarkers)*256-1, path)
+	}
+
+	if hasCustom {
+		if err := writeLvlCustomBlocks(world, gz); err != nil {
+			return err
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+func writeLvlCustomBlocks(world *World, w io.Writer) error {
+	if _, err := w.Write([]uint8{LVL_CUSTOM_BLOCKS_MARKER}); err != nil {
+		return err
+	}
+
+	chunk := make([]uint8, LVL_CHUNK_SIZE*LVL_CHUNK_SIZE*LVL_CHUNK_SIZE)
+	for cy := int16(0); cy < world.SizeY; cy += LVL_CHUNK_SIZE {
+		for cz := int16(0); cz < world.SizeZ; cz += LVL_CHUNK_SIZE {
+			for cx := int16(0); cx < world.SizeX; cx += LVL_CHUNK_SIZE {
+				present := false
+				for i := range chunk {
+					chunk[i] = 0
+				}
+
+				for y := int16(0); y < LVL_CHUNK_SIZE && cy+y < world.SizeY; y++ {
+					for z := int16(0); z < LVL_CHUNK_SIZE && cz+z < world.SizeZ; z++ {
+						for x := int16(0); x < LVL_CHUNK_SIZE && cx+x < world.SizeX; x++ {
+							block := lvlSavedBlock(world.GetBlock(cx+x, cy+y, cz+z))
+							if block > lvlMaxStandardBlock {
+								chunk[(y*LVL_CHUNK_SIZE+z)*LVL_CHUNK_SIZE+x] = uint8(block)
+								present = true
+							}
+						}
+					}
+				}
+
+				if !present {
+					if _, err := w.Write([]uint8{0}); err != nil {
+						return err
+					}
+					continue
+				}
+
+				if _, err := w.Write([]uint8{1}); err != nil {
+					return err
+				}
+				if _, err := w.Write(chunk); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestLvlRoundTrip(t *testing.T) {
+	// Not a multiple of the custom block chunk size, to cover partial chunks
+	for _, maxBlock := range []Block{lvlMaxStandardBlock, BLOCK_MAX_LEGACY, Block(len(lvlCustomBlockMarkers))*256 - 1} {
+		world := newTestWorld(20, 10, 18, maxBlock)
+
+		path := filepath.Join(t.TempDir(), "world.lvl")
+		if err := ExportLvl(world, path); err != nil {
+			t.Fatal(err)
+		}
+
+		imported, err := ImportLvl(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameWorld(t, imported, world)
+	}
+}
+
+// Custom block markers only cover blocks up to 767, so later blocks are saved as their fallbacks,
+// which can be custom blocks themselves
+func TestLvlExportFallbacks(t *testing.T) {
+	SetFallbackBlock(0x301, 200)
+	t.Cleanup(func() { SetFallbackBlock(0x301, uint8(BLOCK_STONE)) })
+
+	world := newTestWorld(4, 4, 4, lvlMaxStandardBlock)
+	world.SetBlock(0, 0, 0, 0x300)
+	world.SetBlock(1, 0, 0, 0x301)
+	world.SetBlock(2, 0, 0, BLOCK_MAX_EXTENDED)
+
+	path := filepath.Join(t.TempDir(), "world.lvl")
+	if err := ExportLvl(world, path); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := ImportLvl(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, expected := range []Block{BLOCK_STONE, 200, BLOCK_STONE} {
+		if block := imported.GetBlock(int16(x), 0, 0); block != expected {
+			t.Errorf("Block %d was saved as %d, expected its fallback %d", world.GetBlock(int16(x), 0, 0), block, expected)
+		}
+	}
+}
+
+// Level as MCGalaxy saves it, 17 blocks wide so the custom block section has two chunks
+func TestImportLvlCustomBlocks(t *testing.T) {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint16{LVL_MAGIC, 17, 1, 1, 0, 0, 0})
+	b.Write([]uint8{0, 0, 0, 0})
+	raw := make([]uint8, 17)
+	copy(raw, []uint8{163, 198, 199, uint8(BLOCK_STONE)})
+	raw[16] = 198
+	b.Write(raw)
+
+	// Only the first chunk is present, so the marker in the second one has the marker's lowest block
+	b.WriteByte(LVL_CUSTOM_BLOCKS_MARKER)
+	b.WriteByte(1)
+	chunk := make([]uint8, LVL_CHUNK_SIZE*LVL_CHUNK_SIZE*LVL_CHUNK_SIZE)
+	copy(chunk, []uint8{100, 1, 2, 50})
+	b.Write(chunk)
+	b.WriteByte(0)
+
+	world, err := ImportLvl(writeGzipFile(t, "world.lvl", b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, expected := range map[int16]Block{0: 100, 1: 0x101, 2: 0x202, 3: BLOCK_STONE, 16: 0x100} {
+		if block := world.GetBlock(x, 0, 0); block != expected {
+			t.Errorf("Block at %d was imported as %d, expected %d", x, block, expected)
+		}
+	}
+}
+
+// Level saved by older MCSharp versions, without the magic number or custom block section
+func TestImportOldLvl(t *testing.T) {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint16{4, 4, 4, 1, 2, 3})
+	b.Write([]uint8{16, 0, 0, 0})
+	raw := make([]uint8, 64)
+	raw[0] = uint8(BLOCK_GOLD_ORE)
+	raw[1] = 105 // Physics air
+	raw[2] = 116 // Physics sand
+	raw[3] = 90  // Unknown
+	b.Write(raw)
+
+	world, err := ImportLvl(writeGzipFile(t, "world.lvl", b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if world.SpawnX != 1.5 || world.SpawnY != 3 || world.SpawnZ != 2.5 || world.SpawnYaw != 16 {
+		t.Errorf("Spawn is %v,%v,%v rotated %d, expected 1.5,3,2.5 rotated 16", world.SpawnX, world.SpawnY, world.SpawnZ, world.SpawnYaw)
+	}
+	for x, expected := range []Block{BLOCK_GOLD_ORE, BLOCK_AIR, BLOCK_SAND, BLOCK_STONE} {
+		if block := world.GetBlock(int16(x), 0, 0); block != expected {
+			t.Errorf("Raw block %d was imported as %d, expected %d", raw[x], block, expected)
+		}
+	}
+}
+
+// Sections after the block array other than custom blocks, such as physics, are skipped
+func TestImportLvlOtherSection(t *testing.T) {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint16{LVL_MAGIC, 2, 2, 2, 0, 0, 0})
+	b.Write([]uint8{0, 0, 0, 0})
+	b.Write(bytes.Repeat([]uint8{uint8(BLOCK_DIRT)}, 8))
+	b.Write([]uint8{0xFC, 1, 2, 3})
+
+	world, err := ImportLvl(writeGzipFile(t, "world.lvl", b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if world.GetBlock(1, 1, 1) != BLOCK_DIRT {
+		t.Errorf("Block was imported as %d, expected dirt", world.GetBlock(1, 1, 1))
+	}
+}
+
+func TestImportLvlOversized(t *testing.T) {
+	for _, size := range [][3]uint16{{math.MaxInt16, math.MaxInt16, math.MaxInt16}, {math.MaxUint16, 4, 4}, {0, 4, 4}} {
+		var b bytes.Buffer
+		binary.Write(&b, binary.LittleEndian, []uint16{LVL_MAGIC, size[0], size[1], size[2], 0, 0, 0})
+		b.Write(make([]uint8, 4+64))
+
+		if _, err := ImportLvl(writeGzipFile(t, "world.lvl", b.Bytes())); err == nil {
+			t.Errorf(".lvl of %dx%dx%d was imported", size[0], size[1], size[2])
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package nbt
+
+import "fmt"
+
+// Tag types, as written before each named tag and list
+const (
+	TAG_END        uint8 = 0
+	TAG_BYTE       uint8 = 1
+	TAG_SHORT      uint8 = 2
+	TAG_INT        uint8 = 3
+	TAG_LONG       uint8 = 4
+	TAG_FLOAT      uint8 = 5
+	TAG_DOUBLE     uint8 = 6
+	TAG_BYTE_ARRAY uint8 = 7
+	TAG_STRING     uint8 = 8
+	TAG_LIST       uint8 = 9
+	TAG_COMPOUND   uint8 = 10
+	TAG_INT_ARRAY  uint8 = 11
+	TAG_LONG_ARRAY uint8 = 12
+)
+
+// Deepest nesting of lists and compounds accepted when reading
+const MAX_DEPTH = 512
+
+// Tag values are one of int8, int16, int32, int64, float32, float64, []byte, string, List, Compound, []int32 or []int64
+type Compound map[string]interface{}
+
+type List struct {
+	Type   uint8
+	Values []interface{}
+}
+
+func NewList(tagType uint8, values ...interface{}) List {
+	return List{
+		Type:   tagType,
+		Values: values,
+	}
+}
+
+func TagType(value interface{}) (uint8, error) {
+	switch value.(type) {
+	case int8:
+		return TAG_BYTE, nil
+	case int16:
+		return TAG_SHORT, nil
+	case int32:
+		return TAG_INT, nil
+	case int64:
+		return TAG_LONG, nil
+	case float32:
+		return TAG_FLOAT, nil
+	case float64:
+		return TAG_DOUBLE, nil
+	case []byte:
+		return TAG_BYTE_ARRAY, nil
+	case string:
+		return TAG_STRING, nil
+	case List:
+		return TAG_LIST, nil
+	case Compound:
+		return TAG_COMPOUND, nil
+	case []int32:
+		return TAG_INT_ARRAY, nil
+	case []int64:
+		return TAG_LONG_ARRAY, nil
+	default:
+		return TAG_END, fmt.Errorf("Unsupported NBT value of type %T", value)
+	}
+}
+
+func (compound Compound) Byte(name string) (int8, bool) {
+	value, ok := compound[name].(int8)
+	return value, ok
+}
+
+func (compound Compound) Short(name string) (int16, bool) {
+	value, ok := compound[name].(int16)
+	return value, ok
+}
+
+func (compound Compound) Int(name string) (int32, bool) {
+	value, ok := compound[name].(int32)
+	return value, ok
+}
+
+func (compound Compound) Long(name string) (int64, bool) {
+	value, ok := compound[name].(int64)
+	return value, ok
+}
+
+func (compound Compound) String(name string) (string, bool) {
+	value, ok := compound[name].(string)
+	return value, ok
+}
+
+func (compound Compound) ByteArray(name string) ([]byte, bool) {
+	value, ok := compound[name].([]byte)
+	return value, ok
+}
+
+// Nested compound, or nil if it is missing or another type
+func (compound Compound) Compound(name string) Compound {
+	value, _ := compound[name].(Compound)
+	return value
+}
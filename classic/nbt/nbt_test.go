@@ -0,0 +1,99 @@
+package nbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func testCompound() Compound {
+	return Compound{
+		"Byte":      int8(-5),
+		"Short":     int16(-300),
+		"Int":       int32(70000),
+		"Long":      int64(1) << 40,
+		"Float":     float32(1.5),
+		"Double":    float64(-2.25),
+		"ByteArray": []byte{1, 2, 3},
+		"String":    "ClassicWorld",
+		"IntArray":  []int32{-1, 0, 1},
+		"LongArray": []int64{1 << 33, -1},
+		"List":      NewList(TAG_SHORT, int16(1), int16(2)),
+		"Compounds": NewList(TAG_COMPOUND, Compound{"X": int16(1)}, Compound{}),
+		"Nested": Compound{
+			"Empty": Compound{},
+		},
+	}
+}
+
+func write(t *testing.T, name string, compound Compound) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	if err := Write(&b, name, compound); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	compound := testCompound()
+	name, read, err := Read(bytes.NewReader(write(t, "Root", compound)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "Root" {
+		t.Errorf("Root compound is named %q, expected \"Root\"", name)
+	}
+	if !reflect.DeepEqual(read, compound) {
+		t.Errorf("Read %#v, expected %#v", read, compound)
+	}
+}
+
+func TestWriteUnsupported(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, "Root", Compound{"Bool": true}); err == nil {
+		t.Error("Compound with an unsupported value was written")
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	data := write(t, "Root", testCompound())
+	for length := 0; length < len(data); length++ {
+		if _, _, err := Read(bytes.NewReader(data[:length])); err == nil {
+			t.Fatalf("NBT truncated to %d of %d bytes was read without an error", length, len(data))
+		}
+	}
+}
+
+func TestReadOversizedArray(t *testing.T) {
+	var b bytes.Buffer
+	b.Write([]byte{TAG_COMPOUND, 0, 0, TAG_BYTE_ARRAY, 0, 1, 'A'})
+	binary.Write(&b, binary.BigEndian, int32(1<<30))
+	b.Write(make([]byte, 16))
+	if _, _, err := Read(&b); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected io.ErrUnexpectedEOF for an array longer than the data, got %v", err)
+	}
+
+	b.Reset()
+	b.Write([]byte{TAG_COMPOUND, 0, 0, TAG_INT_ARRAY, 0, 1, 'A'})
+	binary.Write(&b, binary.BigEndian, int32(-1))
+	if _, _, err := Read(&b); err == nil {
+		t.Error("Array with a negative length was read without an error")
+	}
+}
+
+func TestReadTooDeep(t *testing.T) {
+	var b bytes.Buffer
+	b.Write([]byte{TAG_COMPOUND, 0, 0})
+	for i := 0; i < MAX_DEPTH; i++ {
+		b.Write([]byte{TAG_COMPOUND, 0, 0})
+	}
+	if _, _, err := Read(&b); err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected an error for compounds nested deeper than %d, got %v", MAX_DEPTH, err)
+	}
+}
@@ -0,0 +1,235 @@
+package nbt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+type reader struct {
+	r *bufio.Reader
+}
+
+// Reads the root compound of an uncompressed NBT stream, returning its name
+func Read(r io.Reader) (string, Compound, error) {
+	nr := reader{bufio.NewReader(r)}
+
+	tagType, err := nr.r.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+	if tagType != TAG_COMPOUND {
+		return "", nil, fmt.Errorf("Expected root compound, got tag type %d", tagType)
+	}
+
+	name, err := nr.readString()
+	if err != nil {
+		return "", nil, err
+	}
+
+	compound, err := nr.readCompound(1)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, compound, nil
+}
+
+func (nr *reader) readFull(n int) ([]byte, error) {
+	buffer := make([]byte, n)
+	_, err := io.ReadFull(nr.r, buffer)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buffer, err
+}
+
+func (nr *reader) readUint16() (uint16, error) {
+	buffer, err := nr.readFull(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(buffer), nil
+}
+
+func (nr *reader) readUint32() (uint32, error) {
+	buffer, err := nr.readFull(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buffer), nil
+}
+
+func (nr *reader) readUint64() (uint64, error) {
+	buffer, err := nr.readFull(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buffer), nil
+}
+
+func (nr *reader) readString() (string, error) {
+	length, err := nr.readUint16()
+	if err != nil {
+		return "", err
+	}
+
+	buffer, err := nr.readFull(int(length))
+	return string(buffer), err
+}
+
+// Reads an array length, rejecting negative lengths
+func (nr *reader) readLength() (int, error) {
+	length, err := nr.readUint32()
+	if err != nil {
+		return 0, err
+	}
+	if int32(length) < 0 {
+		return 0, fmt.Errorf("Invalid NBT array length %d", int32(length))
+	}
+	return int(length), nil
+}
+
+// Reads an array without trusting the length to allocate it, so truncated files fail before using the memory
+func (nr *reader) readArray(length int, size int) ([]byte, error) {
+	buffer, err := io.ReadAll(io.LimitReader(nr.r, int64(length)*int64(size)))
+	if err != nil {
+		return nil, err
+	}
+	if len(buffer) != length*size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buffer, nil
+}
+
+func (nr *reader) readCompound(depth int) (Compound, error) {
+	if depth > MAX_DEPTH {
+		return nil, fmt.Errorf("NBT nested deeper than %d", MAX_DEPTH)
+	}
+
+	compound := make(Compound)
+	for {
+		tagType, err := nr.r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if tagType == TAG_END {
+			return compound, nil
+		}
+
+		name, err := nr.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := nr.readPayload(tagType, depth)
+		if err != nil {
+			return nil, err
+		}
+		compound[name] = value
+	}
+}
+
+func (nr *reader) readPayload(tagType uint8, depth int) (interface{}, error) {
+	switch tagType {
+
+	case TAG_BYTE:
+		value, err := nr.r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return int8(value), nil
+
+	case TAG_SHORT:
+		value, err := nr.readUint16()
+		return int16(value), err
+
+	case TAG_INT:
+		value, err := nr.readUint32()
+		return int32(value), err
+
+	case TAG_LONG:
+		value, err := nr.readUint64()
+		return int64(value), err
+
+	case TAG_FLOAT:
+		value, err := nr.readUint32()
+		return math.Float32frombits(value), err
+
+	case TAG_DOUBLE:
+		value, err := nr.readUint64()
+		return math.Float64frombits(value), err
+
+	case TAG_BYTE_ARRAY:
+		length, err := nr.readLength()
+		if err != nil {
+			return nil, err
+		}
+		return nr.readArray(length, 1)
+
+	case TAG_STRING:
+		return nr.readString()
+
+	case TAG_LIST:
+		elementType, err := nr.r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		length, err := nr.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if depth+1 > MAX_DEPTH {
+			return nil, fmt.Errorf("NBT nested deeper than %d", MAX_DEPTH)
+		}
+
+		list := List{Type: elementType}
+		for i := 0; i < length; i++ {
+			value, err := nr.readPayload(elementType, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list.Values = append(list.Values, value)
+		}
+		return list, nil
+
+	case TAG_COMPOUND:
+		return nr.readCompound(depth + 1)
+
+	case TAG_INT_ARRAY:
+		length, err := nr.readLength()
+		if err != nil {
+			return nil, err
+		}
+		buffer, err := nr.readArray(length, 4)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]int32, length)
+		for i := range values {
+			values[i] = int32(binary.BigEndian.Uint32(buffer[i*4:]))
+		}
+		return values, nil
+
+	case TAG_LONG_ARRAY:
+		length, err := nr.readLength()
+		if err != nil {
+			return nil, err
+		}
+		buffer, err := nr.readArray(length, 8)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]int64, length)
+		for i := range values {
+			values[i] = int64(binary.BigEndian.Uint64(buffer[i*8:]))
+		}
+		return values, nil
+
+	default:
+		return nil, fmt.Errorf("Unknown NBT tag type %d", tagType)
+
+	}
+}
@@ -0,0 +1,150 @@
+package nbt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+type writer struct {
+	w *bufio.Writer
+}
+
+// Writes the root compound as an uncompressed NBT stream. Tags are written in name order, so the output is stable
+func Write(w io.Writer, name string, compound Compound) error {
+	nw := writer{bufio.NewWriter(w)}
+
+	nw.w.WriteByte(TAG_COMPOUND)
+	if err := nw.writeString(name); err != nil {
+		return err
+	}
+
+	if err := nw.writeCompound(compound); err != nil {
+		return err
+	}
+
+	return nw.w.Flush()
+}
+
+func (nw *writer) writeUint16(value uint16) {
+	buffer := make([]byte, 2)
+	binary.BigEndian.PutUint16(buffer, value)
+	nw.w.Write(buffer)
+}
+
+func (nw *writer) writeUint32(value uint32) {
+	buffer := make([]byte, 4)
+	binary.BigEndian.PutUint32(buffer, value)
+	nw.w.Write(buffer)
+}
+
+func (nw *writer) writeUint64(value uint64) {
+	buffer := make([]byte, 8)
+	binary.BigEndian.PutUint64(buffer, value)
+	nw.w.Write(buffer)
+}
+
+func (nw *writer) writeString(value string) error {
+	if len(value) > math.MaxUint16 {
+		return fmt.Errorf("NBT string of %d bytes is too long", len(value))
+	}
+
+	nw.writeUint16(uint16(len(value)))
+	_, err := nw.w.WriteString(value)
+	return err
+}
+
+func (nw *writer) writeCompound(compound Compound) error {
+	names := make([]string, 0, len(compound))
+	for name := range compound {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := compound[name]
+		tagType, err := TagType(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		nw.w.WriteByte(tagType)
+		if err := nw.writeString(name); err != nil {
+			return err
+		}
+		if err := nw.writePayload(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nw.w.WriteByte(TAG_END)
+}
+
+func (nw *writer) writePayload(value interface{}) error {
+	switch value := value.(type) {
+
+	case int8:
+		nw.w.WriteByte(uint8(value))
+
+	case int16:
+		nw.writeUint16(uint16(value))
+
+	case int32:
+		nw.writeUint32(uint32(value))
+
+	case int64:
+		nw.writeUint64(uint64(value))
+
+	case float32:
+		nw.writeUint32(math.Float32bits(value))
+
+	case float64:
+		nw.writeUint64(math.Float64bits(value))
+
+	case []byte:
+		nw.writeUint32(uint32(len(value)))
+		nw.w.Write(value)
+
+	case string:
+		return nw.writeString(value)
+
+	case List:
+		nw.w.WriteByte(value.Type)
+		nw.writeUint32(uint32(len(value.Values)))
+		for _, element := range value.Values {
+			if tagType, err := TagType(element); err != nil {
+				return err
+			} else if tagType != value.Type {
+				return fmt.Errorf("List of tag type %d contains tag type %d", value.Type, tagType)
+			}
+			if err := nw.writePayload(element); err != nil {
+				return err
+			}
+		}
+
+	case Compound:
+		return nw.writeCompound(value)
+
+	case []int32:
+		nw.writeUint32(uint32(len(value)))
+		for _, element := range value {
+			nw.writeUint32(uint32(element))
+		}
+
+	case []int64:
+		nw.writeUint32(uint32(len(value)))
+		for _, element := range value {
+			nw.writeUint64(uint64(element))
+		}
+
+	default:
+		_, err := TagType(value)
+		return err
+
+	}
+
+	return nil
+}
@@ -15,12 +15,16 @@ type PlayerChannels struct {
 	setPosition chan SetPositionChannel
 	setBlock    chan SetBlockChannel
 	disconnect  chan DisconnectChannel
+	twoWayPing  chan TwoWayPingChannel
+	playerClick chan PlayerClickChannel
+	plugin      chan PluginMessageChannel
 }
 
 type PlayerIdentificationChannel struct {
-	playerId int8
-	conn     net.Conn
-	packet   packets.UpstreamPlayerIdentification
+	playerId   int8
+	conn       net.Conn
+	packet     packets.UpstreamPlayerIdentification
+	extensions map[string]int32
 }
 
 type MessageChannel struct {
@@ -38,6 +42,21 @@ type SetBlockChannel struct {
 	packet   packets.UpstreamSetBlock
 }
 
+type TwoWayPingChannel struct {
+	playerId int8
+	packet   packets.UpstreamTwoWayPing
+}
+
+type PlayerClickChannel struct {
+	playerId int8
+	packet   packets.UpstreamPlayerClick
+}
+
+type PluginMessageChannel struct {
+	playerId int8
+	packet   packets.UpstreamPluginMessage
+}
+
 type DisconnectChannel struct {
 	playerId int8
 }
@@ -59,6 +78,15 @@ func (server *ClassicServer) Run() {
 	worldWaterTicker := NewWorldWaterTicker()
 	defer worldWaterTicker.Stop()
 
+	pingTicker := NewPingTicker()
+	defer pingTicker.Stop()
+
+	worldUnloadTicker := NewWorldUnloadTicker()
+	defer worldUnloadTicker.Stop()
+
+	environmentTicker := NewEnvironmentTicker()
+	defer environmentTicker.Stop()
+
 	channels := PlayerChannels{
 		getPlayerId: server.PlayerIdChannel,
 		connect:     make(chan PlayerIdentificationChannel),
@@ -66,6 +94,9 @@ func (server *ClassicServer) Run() {
 		setPosition: make(chan SetPositionChannel),
 		setBlock:    make(chan SetBlockChannel),
 		disconnect:  make(chan DisconnectChannel),
+		twoWayPing:  make(chan TwoWayPingChannel),
+		playerClick: make(chan PlayerClickChannel),
+		plugin:      make(chan PluginMessageChannel),
 	}
 
 	heartbeat := func() {
@@ -81,11 +112,6 @@ func (server *ClassicServer) Run() {
 		}
 	}
 
-	saveWorld := func() {
-		world := *server.World
-		go SaveWorld(&world)
-	}
-
 	go server.listen(&channels)
 
 	heartbeat()
@@ -97,16 +123,39 @@ func (server *ClassicServer) Run() {
 			heartbeat()
 
 		case <-worldSaveTicker.C:
-			saveWorld()
+			for _, world := range server.Worlds {
+				server.saveWorldInBackground(world, nil)
+			}
+
+		case <-worldUnloadTicker.C:
+			server.UnloadIdleWorlds()
 
 		case <-worldLavaTicker.C:
-			server.World.UpdateLava(server)
+			for _, world := range server.Worlds {
+				world.UpdateLava(server)
+			}
 
 		case <-worldWaterTicker.C:
-			server.World.UpdateWater(server)
+			for _, world := range server.Worlds {
+				world.UpdateWater(server)
+			}
+
+		case <-environmentTicker.C:
+			for _, world := range server.Worlds {
+				server.UpdateEnvironment(world)
+			}
+
+		case task := <-server.mainLoopTasks:
+			task()
+
+		case <-pingTicker.C:
+			for _, player := range server.Players {
+				player.SendPing()
+			}
 
 		case inc := <-channels.connect:
 			player := NewPlayer(server, inc.playerId, inc.conn, inc.packet.Username)
+			player.Extensions = inc.extensions
 			server.ConnectPlayer(player, inc.packet.Verification)
 
 		case inc := <-channels.disconnect:
@@ -121,6 +170,9 @@ func (server *ClassicServer) Run() {
 
 		case inc := <-channels.setPosition:
 			player := server.GetPlayer(inc.playerId)
+			if player == nil {
+				break
+			}
 			player.X = inc.packet.X
 			player.Y = inc.packet.Y
 			player.Z = inc.packet.Z
@@ -129,13 +181,40 @@ func (server *ClassicServer) Run() {
 			server.SetPosition(player, inc.packet.X, inc.packet.Y, inc.packet.Z, inc.packet.Yaw, inc.packet.Pitch)
 
 		case inc := <-channels.setBlock:
-			if inc.packet.Mode == BUILD_PLACE {
-				server.SetBlock(inc.packet.X, inc.packet.Y, inc.packet.Z, inc.packet.Block)
+			player := server.GetPlayer(inc.playerId)
+			if player == nil || !player.World.ValidBlock(inc.packet.X, inc.packet.Y, inc.packet.Z) {
+				break
+			}
+
+			if player.World.ReadOnly {
+				player.RevertBlock(inc.packet.X, inc.packet.Y, inc.packet.Z)
+			} else if inc.packet.Mode == BUILD_PLACE {
+				server.SetBlock(player.World, inc.packet.X, inc.packet.Y, inc.packet.Z, inc.packet.Block)
 			} else if inc.packet.Mode == BUILD_DESTROY {
-				server.SetBlock(inc.packet.X, inc.packet.Y, inc.packet.Z, BLOCK_AIR)
+				server.SetBlock(player.World, inc.packet.X, inc.packet.Y, inc.packet.Z, BLOCK_AIR)
+			}
+
+		case inc := <-channels.twoWayPing:
+			player := server.GetPlayer(inc.playerId)
+			if player != nil {
+				player.HandlePing(inc.packet.Direction, inc.packet.Data)
+			}
+
+		case inc := <-channels.playerClick:
+			player := server.GetPlayer(inc.playerId)
+			if player != nil {
+				server.HandleClick(player, inc.packet)
+			}
+
+		case inc := <-channels.plugin:
+			player := server.GetPlayer(inc.playerId)
+			if player != nil {
+				server.HandlePluginMessage(player, inc.packet)
 			}
 
 		}
+
+		server.FlushBlockChanges()
 	}
 }
 
@@ -164,6 +243,19 @@ func (server *ClassicServer) HandleConnection(conn net.Conn, channels *PlayerCha
 		return
 	}
 
+	extensions := make(map[string]int32)
+	if playerIdentificationPacket.Type == packets.CPE_MAGIC {
+		extensions, err = negotiateExtensions(conn, reader)
+		if err != nil {
+			log.Println(err)
+			conn.Close()
+			return
+		}
+	}
+
+	_, extPositions := extensions[packets.EXT_ENTITY_POSITIONS]
+	_, extBlocks := extensions[packets.EXT_EXTENDED_BLOCKS]
+
 	if len(channels.getPlayerId) == 0 {
 		packets.NewDownstreamDisconnectPlayer("Server is full").Write(conn)
 		conn.Close()
@@ -173,9 +265,10 @@ func (server *ClassicServer) HandleConnection(conn net.Conn, channels *PlayerCha
 	playerId := <-channels.getPlayerId
 
 	channels.connect <- PlayerIdentificationChannel{
-		playerId: playerId,
-		conn:     conn,
-		packet:   *playerIdentificationPacket,
+		playerId:   playerId,
+		conn:       conn,
+		packet:     *playerIdentificationPacket,
+		extensions: extensions,
 	}
 
 	disconnect := func() {
@@ -205,7 +298,7 @@ func (server *ClassicServer) HandleConnection(conn net.Conn, channels *PlayerCha
 			}
 
 		case packets.UPSTREAM_SET_BLOCK:
-			packet, err := packets.ReadUpstreamSetBlock(reader)
+			packet, err := packets.ReadUpstreamSetBlock(reader, extBlocks)
 			if err != nil {
 				return
 			}
@@ -216,7 +309,7 @@ func (server *ClassicServer) HandleConnection(conn net.Conn, channels *PlayerCha
 			break
 
 		case packets.UPSTREAM_SET_POSITION:
-			packet, err := packets.ReadUpstreamSetPosition(reader)
+			packet, err := packets.ReadUpstreamSetPosition(reader, extPositions)
 			if err != nil {
 				return
 			}
@@ -225,6 +318,36 @@ func (server *ClassicServer) HandleConnection(conn net.Conn, channels *PlayerCha
 				packet:   *packet,
 			}
 
+		case packets.UPSTREAM_TWO_WAY_PING:
+			packet, err := packets.ReadUpstreamTwoWayPing(reader)
+			if err != nil {
+				return
+			}
+			channels.twoWayPing <- TwoWayPingChannel{
+				playerId: playerId,
+				packet:   *packet,
+			}
+
+		case packets.UPSTREAM_PLAYER_CLICK:
+			packet, err := packets.ReadUpstreamPlayerClick(reader)
+			if err != nil {
+				return
+			}
+			channels.playerClick <- PlayerClickChannel{
+				playerId: playerId,
+				packet:   *packet,
+			}
+
+		case packets.UPSTREAM_PLUGIN_MESSAGE:
+			packet, err := packets.ReadUpstreamPluginMessage(reader)
+			if err != nil {
+				return
+			}
+			channels.plugin <- PluginMessageChannel{
+				playerId: playerId,
+				packet:   *packet,
+			}
+
 		case packets.UPSTREAM_PLAYER_IDENTIFICATION:
 			disconnect()
 
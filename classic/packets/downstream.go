@@ -68,6 +68,8 @@ func (packet *DownstreamPing) Write(conn net.Conn) error {
 
 type DownstreamLevelInit struct {
 	DownstreamPacket
+	FastMap bool
+	Volume  int32
 }
 
 func NewDownstreamLevelInit() DownstreamLevelInit {
@@ -78,8 +80,22 @@ func NewDownstreamLevelInit() DownstreamLevelInit {
 	}
 }
 
+// Level Init for clients supporting FastMap, which carries the volume ahead of the level data
+func NewDownstreamFastMapLevelInit(volume int32) DownstreamLevelInit {
+	return DownstreamLevelInit{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_LEVEL_INIT,
+		},
+		FastMap: true,
+		Volume:  volume,
+	}
+}
+
 func (packet DownstreamLevelInit) Write(conn net.Conn) error {
 	buffer := []byte{byte(packet.Id)}
+	if packet.FastMap {
+		buffer = append(buffer, writeInt(packet.Volume)...)
+	}
 	_, err := conn.Write(buffer)
 	return err
 }
@@ -157,10 +173,11 @@ func (packet DownstreamLevelFinalize) Write(conn net.Conn) error {
 
 type DownstreamSetBlock struct {
 	DownstreamPacket
-	X     int16
-	Y     int16
-	Z     int16
-	Block uint8
+	X         int16
+	Y         int16
+	Z         int16
+	Block     Block
+	ExtBlocks bool
 }
 
 func NewDownstreamSetBlock(x int16, y int16, z int16, block Block) DownstreamSetBlock {
@@ -175,12 +192,17 @@ func NewDownstreamSetBlock(x int16, y int16, z int16, block Block) DownstreamSet
 	}
 }
 
+func (packet DownstreamSetBlock) WithExtBlocks(extBlocks bool) DownstreamPacketInterface {
+	packet.ExtBlocks = extBlocks
+	return packet
+}
+
 func (packet DownstreamSetBlock) Write(conn net.Conn) error {
 	buffer := []byte{byte(packet.Id)}
 	buffer = append(buffer, writeShort(packet.X)...)
 	buffer = append(buffer, writeShort(packet.Y)...)
 	buffer = append(buffer, writeShort(packet.Z)...)
-	buffer = append(buffer, packet.Block)
+	buffer = append(buffer, writeBlock(packet.Block, packet.ExtBlocks)...)
 	_, err := conn.Write(buffer)
 	return err
 }
@@ -191,13 +213,14 @@ func (packet DownstreamSetBlock) Write(conn net.Conn) error {
 
 type DownstreamSpawnPlayer struct {
 	DownstreamPacket
-	PlayerId   int8
-	PlayerName string
-	X          FPShort
-	Y          FPShort
-	Z          FPShort
-	Yaw        uint8
-	Pitch      uint8
+	PlayerId     int8
+	PlayerName   string
+	X            FPShort
+	Y            FPShort
+	Z            FPShort
+	Yaw          uint8
+	Pitch        uint8
+	ExtPositions bool
 }
 
 func NewDownstreamSpawnPlayer(playerId int8, playerName string, x FPShort, y FPShort, z FPShort, yaw uint8, pitch uint8) DownstreamSpawnPlayer {
@@ -215,13 +238,18 @@ func NewDownstreamSpawnPlayer(playerId int8, playerName string, x FPShort, y FPS
 	}
 }
 
+func (packet DownstreamSpawnPlayer) WithExtPositions(extPositions bool) DownstreamPacketInterface {
+	packet.ExtPositions = extPositions
+	return packet
+}
+
 func (packet DownstreamSpawnPlayer) Write(conn net.Conn) error {
 	buffer := []byte{byte(packet.Id)}
 	buffer = append(buffer, byte(packet.PlayerId))
 	buffer = append(buffer, writeString(packet.PlayerName)...)
-	buffer = append(buffer, writeFPShort(packet.X)...)
-	buffer = append(buffer, writeFPShort(packet.Y)...)
-	buffer = append(buffer, writeFPShort(packet.Z)...)
+	buffer = append(buffer, writePosition(packet.X, packet.ExtPositions)...)
+	buffer = append(buffer, writePosition(packet.Y, packet.ExtPositions)...)
+	buffer = append(buffer, writePosition(packet.Z, packet.ExtPositions)...)
 	buffer = append(buffer, packet.Yaw)
 	buffer = append(buffer, packet.Pitch)
 	_, err := conn.Write(buffer)
@@ -234,12 +262,13 @@ func (packet DownstreamSpawnPlayer) Write(conn net.Conn) error {
 
 type DownstreamSetPosition struct {
 	DownstreamPacket
-	PlayerId int8
-	X        FPShort
-	Y        FPShort
-	Z        FPShort
-	Yaw      uint8
-	Pitch    uint8
+	PlayerId     int8
+	X            FPShort
+	Y            FPShort
+	Z            FPShort
+	Yaw          uint8
+	Pitch        uint8
+	ExtPositions bool
 }
 
 func NewDownstreamSetPosition(playerId int8, x FPShort, y FPShort, z FPShort, yaw uint8, pitch uint8) DownstreamSetPosition {
@@ -256,12 +285,17 @@ func NewDownstreamSetPosition(playerId int8, x FPShort, y FPShort, z FPShort, ya
 	}
 }
 
+func (packet DownstreamSetPosition) WithExtPositions(extPositions bool) DownstreamPacketInterface {
+	packet.ExtPositions = extPositions
+	return packet
+}
+
 func (packet DownstreamSetPosition) Write(conn net.Conn) error {
 	buffer := []byte{byte(packet.Id)}
 	buffer = append(buffer, byte(packet.PlayerId))
-	buffer = append(buffer, writeFPShort(packet.X)...)
-	buffer = append(buffer, writeFPShort(packet.Y)...)
-	buffer = append(buffer, writeFPShort(packet.Z)...)
+	buffer = append(buffer, writePosition(packet.X, packet.ExtPositions)...)
+	buffer = append(buffer, writePosition(packet.Y, packet.ExtPositions)...)
+	buffer = append(buffer, writePosition(packet.Z, packet.ExtPositions)...)
 	buffer = append(buffer, packet.Yaw)
 	buffer = append(buffer, packet.Pitch)
 	_, err := conn.Write(buffer)
@@ -379,3 +413,623 @@ func (packet DownstreamUpdatePlayerMode) Write(conn net.Conn) error {
 	_, err := conn.Write(buffer)
 	return err
 }
+
+//
+// Extension Info
+//
+
+type DownstreamExtInfo struct {
+	DownstreamPacket
+	AppName        string
+	ExtensionCount int16
+}
+
+func NewDownstreamExtInfo(appName string, extensionCount int16) DownstreamExtInfo {
+	return DownstreamExtInfo{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_EXT_INFO,
+		},
+		AppName:        appName,
+		ExtensionCount: extensionCount,
+	}
+}
+
+func (packet DownstreamExtInfo) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, writeString(packet.AppName)...)
+	buffer = append(buffer, writeShort(packet.ExtensionCount)...)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Extension Entry
+//
+
+type DownstreamExtEntry struct {
+	DownstreamPacket
+	ExtName string
+	Version int32
+}
+
+func NewDownstreamExtEntry(extName string, version int32) DownstreamExtEntry {
+	return DownstreamExtEntry{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_EXT_ENTRY,
+		},
+		ExtName: extName,
+		Version: version,
+	}
+}
+
+func (packet DownstreamExtEntry) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, writeString(packet.ExtName)...)
+	buffer = append(buffer, writeInt(packet.Version)...)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Two Way Ping
+//
+
+type DownstreamTwoWayPing struct {
+	DownstreamPacket
+	Direction uint8
+	Data      int16
+}
+
+func NewDownstreamTwoWayPing(direction uint8, data int16) DownstreamTwoWayPing {
+	return DownstreamTwoWayPing{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_TWO_WAY_PING,
+		},
+		Direction: direction,
+		Data:      data,
+	}
+}
+
+func (packet DownstreamTwoWayPing) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, packet.Direction)
+	buffer = append(buffer, writeShort(packet.Data)...)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Change Model
+//
+
+type DownstreamChangeModel struct {
+	DownstreamPacket
+	EntityId int8
+	Model    string
+}
+
+func NewDownstreamChangeModel(entityId int8, model string) DownstreamChangeModel {
+	return DownstreamChangeModel{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_CHANGE_MODEL,
+		},
+		EntityId: entityId,
+		Model:    model,
+	}
+}
+
+func (packet DownstreamChangeModel) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, byte(packet.EntityId))
+	buffer = append(buffer, writeString(packet.Model)...)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Hack Control
+//
+
+type DownstreamHackControl struct {
+	DownstreamPacket
+	Flying          bool
+	NoClip          bool
+	Speeding        bool
+	SpawnControl    bool
+	ThirdPersonView bool
+	JumpHeight      int16
+}
+
+func NewDownstreamHackControl(flying bool, noClip bool, speeding bool, spawnControl bool, thirdPersonView bool, jumpHeight int16) DownstreamHackControl {
+	return DownstreamHackControl{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_HACK_CONTROL,
+		},
+		Flying:          flying,
+		NoClip:          noClip,
+		Speeding:        speeding,
+		SpawnControl:    spawnControl,
+		ThirdPersonView: thirdPersonView,
+		JumpHeight:      jumpHeight,
+	}
+}
+
+func (packet DownstreamHackControl) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, writeBool(packet.Flying))
+	buffer = append(buffer, writeBool(packet.NoClip))
+	buffer = append(buffer, writeBool(packet.Speeding))
+	buffer = append(buffer, writeBool(packet.SpawnControl))
+	buffer = append(buffer, writeBool(packet.ThirdPersonView))
+	buffer = append(buffer, writeShort(packet.JumpHeight)...)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Bulk Block Update
+//
+
+const BULK_BLOCK_UPDATE_SIZE = 256
+
+type DownstreamBulkBlockUpdate struct {
+	DownstreamPacket
+	Indices   []int32
+	Blocks    []Block
+	ExtBlocks bool
+}
+
+// Takes up to BULK_BLOCK_UPDATE_SIZE block indices, each (y * SizeZ + z) * SizeX + x, and their blocks
+func NewDownstreamBulkBlockUpdate(indices []int32, blocks []Block) DownstreamBulkBlockUpdate {
+	return DownstreamBulkBlockUpdate{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_BULK_BLOCK_UPDATE,
+		},
+		Indices: indices,
+		Blocks:  blocks,
+	}
+}
+
+func (packet DownstreamBulkBlockUpdate) WithExtBlocks(extBlocks bool) DownstreamPacketInterface {
+	packet.ExtBlocks = extBlocks
+	return packet
+}
+
+func (packet DownstreamBulkBlockUpdate) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, uint8(len(packet.Indices)-1))
+	for i := 0; i < BULK_BLOCK_UPDATE_SIZE; i++ {
+		if i < len(packet.Indices) {
+			buffer = append(buffer, writeInt(packet.Indices[i])...)
+		} else {
+			buffer = append(buffer, writeInt(0)...)
+		}
+	}
+	for i := 0; i < BULK_BLOCK_UPDATE_SIZE; i++ {
+		if i >= len(packet.Blocks) {
+			buffer = append(buffer, 0)
+		} else if packet.ExtBlocks {
+			buffer = append(buffer, uint8(packet.Blocks[i]))
+		} else {
+			buffer = append(buffer, FallbackBlock(packet.Blocks[i]))
+		}
+	}
+	if packet.ExtBlocks {
+		// Upper 2 bits of each block, packed 4 to a byte starting from the lowest bits
+		for i := 0; i < BULK_BLOCK_UPDATE_SIZE; i += 4 {
+			upper := uint8(0)
+			for j := 0; j < 4 && i+j < len(packet.Blocks); j++ {
+				upper |= uint8((packet.Blocks[i+j]>>8)&0x03) << (2 * j)
+			}
+			buffer = append(buffer, upper)
+		}
+	}
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Set Text Hot Key
+//
+
+type DownstreamSetTextHotKey struct {
+	DownstreamPacket
+	Label   string
+	Action  string
+	KeyCode int32
+	KeyMods uint8
+}
+
+// An empty action removes the binding for the key
+func NewDownstreamSetTextHotKey(label string, action string, keyCode int32, keyMods uint8) DownstreamSetTextHotKey {
+	return DownstreamSetTextHotKey{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_SET_TEXT_HOTKEY,
+		},
+		Label:   label,
+		Action:  action,
+		KeyCode: keyCode,
+		KeyMods: keyMods,
+	}
+}
+
+func (packet DownstreamSetTextHotKey) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, writeString(packet.Label)...)
+	buffer = append(buffer, writeString(packet.Action)...)
+	buffer = append(buffer, writeInt(packet.KeyCode)...)
+	buffer = append(buffer, packet.KeyMods)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Set Spawnpoint
+//
+
+type DownstreamSetSpawnpoint struct {
+	DownstreamPacket
+	X            FPShort
+	Y            FPShort
+	Z            FPShort
+	Yaw          uint8
+	Pitch        uint8
+	ExtPositions bool
+}
+
+func NewDownstreamSetSpawnpoint(x FPShort, y FPShort, z FPShort, yaw uint8, pitch uint8) DownstreamSetSpawnpoint {
+	return DownstreamSetSpawnpoint{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_SET_SPAWNPOINT,
+		},
+		X:     x,
+		Y:     y,
+		Z:     z,
+		Yaw:   yaw,
+		Pitch: pitch,
+	}
+}
+
+func (packet DownstreamSetSpawnpoint) WithExtPositions(extPositions bool) DownstreamPacketInterface {
+	packet.ExtPositions = extPositions
+	return packet
+}
+
+func (packet DownstreamSetSpawnpoint) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, writePosition(packet.X, packet.ExtPositions)...)
+	buffer = append(buffer, writePosition(packet.Y, packet.ExtPositions)...)
+	buffer = append(buffer, writePosition(packet.Z, packet.ExtPositions)...)
+	buffer = append(buffer, packet.Yaw)
+	buffer = append(buffer, packet.Pitch)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Velocity Control
+//
+
+type DownstreamVelocityControl struct {
+	DownstreamPacket
+	X     float64
+	Y     float64
+	Z     float64
+	XMode uint8
+	YMode uint8
+	ZMode uint8
+}
+
+func NewDownstreamVelocityControl(x float64, y float64, z float64, xMode uint8, yMode uint8, zMode uint8) DownstreamVelocityControl {
+	return DownstreamVelocityControl{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_VELOCITY_CONTROL,
+		},
+		X:     x,
+		Y:     y,
+		Z:     z,
+		XMode: xMode,
+		YMode: yMode,
+		ZMode: zMode,
+	}
+}
+
+func (packet DownstreamVelocityControl) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, writeFixedInt(packet.X)...)
+	buffer = append(buffer, writeFixedInt(packet.Y)...)
+	buffer = append(buffer, writeFixedInt(packet.Z)...)
+	buffer = append(buffer, packet.XMode)
+	buffer = append(buffer, packet.YMode)
+	buffer = append(buffer, packet.ZMode)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Define Effect
+//
+
+type DownstreamDefineEffect struct {
+	DownstreamPacket
+	EffectId          uint8
+	U1                uint8
+	V1                uint8
+	U2                uint8
+	V2                uint8
+	Red               uint8
+	Green             uint8
+	Blue              uint8
+	FrameCount        uint8
+	ParticleCount     uint8
+	Size              uint8 // In 1/32 of a block
+	SizeVariation     float64
+	Spread            float64
+	Speed             float64
+	Gravity           float64
+	Lifetime          float64
+	LifetimeVariation float64
+	CollideFlags      uint8
+	FullBright        bool
+}
+
+// Only sets the effect ID; the appearance is set through the fields
+func NewDownstreamDefineEffect(effectId uint8) DownstreamDefineEffect {
+	return DownstreamDefineEffect{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_DEFINE_EFFECT,
+		},
+		EffectId: effectId,
+	}
+}
+
+func (packet DownstreamDefineEffect) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, packet.EffectId)
+	buffer = append(buffer, packet.U1, packet.V1, packet.U2, packet.V2)
+	buffer = append(buffer, packet.Red, packet.Green, packet.Blue)
+	buffer = append(buffer, packet.FrameCount)
+	buffer = append(buffer, packet.ParticleCount)
+	buffer = append(buffer, packet.Size)
+	buffer = append(buffer, writeFixedInt(packet.SizeVariation)...)
+	buffer = append(buffer, writeShort(int16(uint16(packet.Spread*32)))...)
+	buffer = append(buffer, writeFixedInt(packet.Speed)...)
+	buffer = append(buffer, writeFixedInt(packet.Gravity)...)
+	buffer = append(buffer, writeFixedInt(packet.Lifetime)...)
+	buffer = append(buffer, writeFixedInt(packet.LifetimeVariation)...)
+	buffer = append(buffer, packet.CollideFlags)
+	buffer = append(buffer, writeBool(packet.FullBright))
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Spawn Effect
+//
+
+type DownstreamSpawnEffect struct {
+	DownstreamPacket
+	EffectId uint8
+	X        FPShort
+	Y        FPShort
+	Z        FPShort
+	OriginX  FPShort
+	OriginY  FPShort
+	OriginZ  FPShort
+}
+
+func NewDownstreamSpawnEffect(effectId uint8, x FPShort, y FPShort, z FPShort, originX FPShort, originY FPShort, originZ FPShort) DownstreamSpawnEffect {
+	return DownstreamSpawnEffect{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_SPAWN_EFFECT,
+		},
+		EffectId: effectId,
+		X:        x,
+		Y:        y,
+		Z:        z,
+		OriginX:  originX,
+		OriginY:  originY,
+		OriginZ:  originZ,
+	}
+}
+
+func (packet DownstreamSpawnEffect) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, packet.EffectId)
+	buffer = append(buffer, writeFPInt(packet.X)...)
+	buffer = append(buffer, writeFPInt(packet.Y)...)
+	buffer = append(buffer, writeFPInt(packet.Z)...)
+	buffer = append(buffer, writeFPInt(packet.OriginX)...)
+	buffer = append(buffer, writeFPInt(packet.OriginY)...)
+	buffer = append(buffer, writeFPInt(packet.OriginZ)...)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Environment Set Color
+//
+
+type DownstreamEnvSetColor struct {
+	DownstreamPacket
+	Variable uint8
+	Red      int16
+	Green    int16
+	Blue     int16
+}
+
+// Components of -1 reset the variable to the client default
+func NewDownstreamEnvSetColor(variable uint8, red int16, green int16, blue int16) DownstreamEnvSetColor {
+	return DownstreamEnvSetColor{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_ENV_SET_COLOR,
+		},
+		Variable: variable,
+		Red:      red,
+		Green:    green,
+		Blue:     blue,
+	}
+}
+
+func (packet DownstreamEnvSetColor) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, packet.Variable)
+	buffer = append(buffer, writeShort(packet.Red)...)
+	buffer = append(buffer, writeShort(packet.Green)...)
+	buffer = append(buffer, writeShort(packet.Blue)...)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Environment Set Weather Type
+//
+
+type DownstreamEnvSetWeatherType struct {
+	DownstreamPacket
+	Weather uint8
+}
+
+func NewDownstreamEnvSetWeatherType(weather uint8) DownstreamEnvSetWeatherType {
+	return DownstreamEnvSetWeatherType{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_ENV_SET_WEATHER_TYPE,
+		},
+		Weather: weather,
+	}
+}
+
+func (packet DownstreamEnvSetWeatherType) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, packet.Weather)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Lighting Mode
+//
+
+type DownstreamLightingMode struct {
+	DownstreamPacket
+	Mode   uint8
+	Locked bool
+}
+
+func NewDownstreamLightingMode(mode uint8, locked bool) DownstreamLightingMode {
+	return DownstreamLightingMode{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_LIGHTING_MODE,
+		},
+		Mode:   mode,
+		Locked: locked,
+	}
+}
+
+func (packet DownstreamLightingMode) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, packet.Mode)
+	buffer = append(buffer, writeBool(packet.Locked))
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Set Text Color
+//
+
+type DownstreamSetTextColor struct {
+	DownstreamPacket
+	Red   uint8
+	Green uint8
+	Blue  uint8
+	Alpha uint8
+	Code  uint8
+}
+
+func NewDownstreamSetTextColor(red uint8, green uint8, blue uint8, alpha uint8, code uint8) DownstreamSetTextColor {
+	return DownstreamSetTextColor{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_SET_TEXT_COLOR,
+		},
+		Red:   red,
+		Green: green,
+		Blue:  blue,
+		Alpha: alpha,
+		Code:  code,
+	}
+}
+
+func (packet DownstreamSetTextColor) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, packet.Red, packet.Green, packet.Blue, packet.Alpha)
+	buffer = append(buffer, packet.Code)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Plugin Message
+//
+
+type DownstreamPluginMessage struct {
+	DownstreamPacket
+	Channel uint8
+	Data    [PLUGIN_MESSAGE_SIZE]uint8
+}
+
+func NewDownstreamPluginMessage(channel uint8, data [PLUGIN_MESSAGE_SIZE]uint8) DownstreamPluginMessage {
+	return DownstreamPluginMessage{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_PLUGIN_MESSAGE,
+		},
+		Channel: channel,
+		Data:    data,
+	}
+}
+
+func (packet DownstreamPluginMessage) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, packet.Channel)
+	buffer = append(buffer, packet.Data[:]...)
+	_, err := conn.Write(buffer)
+	return err
+}
+
+//
+// Set Inventory Order
+//
+
+type DownstreamSetInventoryOrder struct {
+	DownstreamPacket
+	Block     Block
+	Order     Block // Position in the inventory starting from 1, or 0 to hide the block
+	ExtBlocks bool
+}
+
+func NewDownstreamSetInventoryOrder(block Block, order Block) DownstreamSetInventoryOrder {
+	return DownstreamSetInventoryOrder{
+		DownstreamPacket: DownstreamPacket{
+			Id: DOWNSTREAM_SET_INVENTORY_ORDER,
+		},
+		Block: block,
+		Order: order,
+	}
+}
+
+func (packet DownstreamSetInventoryOrder) WithExtBlocks(extBlocks bool) DownstreamPacketInterface {
+	packet.ExtBlocks = extBlocks
+	return packet
+}
+
+func (packet DownstreamSetInventoryOrder) Write(conn net.Conn) error {
+	buffer := []byte{byte(packet.Id)}
+	buffer = append(buffer, writeBlock(packet.Block, packet.ExtBlocks)...)
+	if packet.ExtBlocks {
+		buffer = append(buffer, writeShort(int16(packet.Order))...)
+	} else {
+		buffer = append(buffer, uint8(packet.Order))
+	}
+	_, err := conn.Write(buffer)
+	return err
+}
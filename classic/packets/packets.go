@@ -23,6 +23,11 @@ const (
 	UPSTREAM_SET_BLOCK             UpstreamPacketID = 0x05
 	UPSTREAM_SET_POSITION          UpstreamPacketID = 0x08
 	UPSTREAM_MESSAGE               UpstreamPacketID = 0x0D
+	UPSTREAM_EXT_INFO              UpstreamPacketID = 0x10
+	UPSTREAM_EXT_ENTRY             UpstreamPacketID = 0x11
+	UPSTREAM_PLAYER_CLICK          UpstreamPacketID = 0x22
+	UPSTREAM_TWO_WAY_PING          UpstreamPacketID = 0x2B
+	UPSTREAM_PLUGIN_MESSAGE        UpstreamPacketID = 0x35
 )
 
 // Downstream Packets
@@ -34,6 +39,17 @@ type DownstreamPacketInterface interface {
 	Write(conn net.Conn) error
 }
 
+// Packets carrying entity positions, which are sent as FPInts to clients supporting ExtEntityPositions
+type ExtPositionsPacket interface {
+	WithExtPositions(extPositions bool) DownstreamPacketInterface
+}
+
+// Packets carrying block IDs, which are sent as shorts to clients supporting ExtendedBlocks
+// and as fallback blocks to others
+type ExtBlocksPacket interface {
+	WithExtBlocks(extBlocks bool) DownstreamPacketInterface
+}
+
 type DownstreamPacket struct {
 	DownstreamPacketInterface
 	Id DownstreamPacketID
@@ -52,6 +68,112 @@ const (
 	DOWNSTREAM_MESSAGE               DownstreamPacketID = 0x0D
 	DOWNSTREAM_DISCONNECT_PLAYER     DownstreamPacketID = 0x0E
 	DOWNSTREAM_UPDATE_PLAYER_MODE    DownstreamPacketID = 0x0F
+	DOWNSTREAM_EXT_INFO              DownstreamPacketID = 0x10
+	DOWNSTREAM_EXT_ENTRY             DownstreamPacketID = 0x11
+	DOWNSTREAM_SET_TEXT_HOTKEY       DownstreamPacketID = 0x15
+	DOWNSTREAM_ENV_SET_COLOR         DownstreamPacketID = 0x19
+	DOWNSTREAM_CHANGE_MODEL          DownstreamPacketID = 0x1D
+	DOWNSTREAM_ENV_SET_WEATHER_TYPE  DownstreamPacketID = 0x1F
+	DOWNSTREAM_HACK_CONTROL          DownstreamPacketID = 0x20
+	DOWNSTREAM_BULK_BLOCK_UPDATE     DownstreamPacketID = 0x26
+	DOWNSTREAM_SET_TEXT_COLOR        DownstreamPacketID = 0x27
+	DOWNSTREAM_SET_INVENTORY_ORDER   DownstreamPacketID = 0x2C
+	DOWNSTREAM_SET_SPAWNPOINT        DownstreamPacketID = 0x2E
+	DOWNSTREAM_VELOCITY_CONTROL      DownstreamPacketID = 0x2F
+	DOWNSTREAM_DEFINE_EFFECT         DownstreamPacketID = 0x30
+	DOWNSTREAM_SPAWN_EFFECT          DownstreamPacketID = 0x31
+	DOWNSTREAM_PLUGIN_MESSAGE        DownstreamPacketID = 0x35
+	DOWNSTREAM_LIGHTING_MODE         DownstreamPacketID = 0x37
+	DOWNSTREAM_TWO_WAY_PING          DownstreamPacketID = 0x2B
+)
+
+// Classic Protocol Extension
+// https://wiki.vg/Classic_Protocol_Extension
+
+// Sent in place of the unused byte of Player Identification by clients supporting CPE
+const CPE_MAGIC uint8 = 0x42
+
+const (
+	EXT_TWO_WAY_PING      = "TwoWayPing"
+	EXT_CHANGE_MODEL      = "ChangeModel"
+	EXT_HACK_CONTROL      = "HackControl"
+	EXT_ENTITY_POSITIONS  = "ExtEntityPositions"
+	EXT_FAST_MAP          = "FastMap"
+	EXT_BULK_BLOCK_UPDATE = "BulkBlockUpdate"
+	EXT_PLAYER_CLICK      = "PlayerClick"
+	EXT_TEXT_HOTKEY       = "TextHotKey"
+	EXT_SET_SPAWNPOINT    = "SetSpawnpoint"
+	EXT_VELOCITY_CONTROL  = "VelocityControl"
+	EXT_CUSTOM_PARTICLES  = "CustomParticles"
+	EXT_EXTENDED_BLOCKS   = "ExtendedBlocks"
+	EXT_ENV_COLORS        = "EnvColors"
+	EXT_ENV_WEATHER_TYPE  = "EnvWeatherType"
+	EXT_LIGHTING_MODE     = "LightingMode"
+	EXT_TEXT_COLORS       = "TextColors"
+	EXT_PLUGIN_MESSAGES   = "PluginMessages"
+	EXT_INVENTORY_ORDER   = "InventoryOrder"
+)
+
+const (
+	CLICK_BUTTON_LEFT   uint8 = 0x00
+	CLICK_BUTTON_RIGHT  uint8 = 0x01
+	CLICK_BUTTON_MIDDLE uint8 = 0x02
+)
+
+const (
+	CLICK_ACTION_PRESS   uint8 = 0x00
+	CLICK_ACTION_RELEASE uint8 = 0x01
+)
+
+const (
+	FACE_AWAY_X    uint8 = 0x00
+	FACE_TOWARDS_X uint8 = 0x01
+	FACE_AWAY_Y    uint8 = 0x02
+	FACE_TOWARDS_Y uint8 = 0x03
+	FACE_AWAY_Z    uint8 = 0x04
+	FACE_TOWARDS_Z uint8 = 0x05
+	FACE_NONE      uint8 = 0x06
+)
+
+const (
+	KEY_MOD_CTRL  uint8 = 0x01
+	KEY_MOD_SHIFT uint8 = 0x02
+	KEY_MOD_ALT   uint8 = 0x04
+)
+
+const (
+	VELOCITY_ADD uint8 = 0x00
+	VELOCITY_SET uint8 = 0x01
+)
+
+const (
+	ENV_COLOR_SKY    uint8 = 0x00
+	ENV_COLOR_CLOUD  uint8 = 0x01
+	ENV_COLOR_FOG    uint8 = 0x02
+	ENV_COLOR_SHADOW uint8 = 0x03
+	ENV_COLOR_SUN    uint8 = 0x04
+)
+
+const (
+	WEATHER_SUN  uint8 = 0x00
+	WEATHER_RAIN uint8 = 0x01
+	WEATHER_SNOW uint8 = 0x02
+)
+
+const (
+	LIGHTING_DEFAULT uint8 = 0x00
+	LIGHTING_CLASSIC uint8 = 0x01
+	LIGHTING_FANCY   uint8 = 0x02
+)
+
+const PLUGIN_MESSAGE_SIZE = 64
+
+// Target entity of a Player Click that did not target an entity
+const CLICK_NO_ENTITY int8 = -1
+
+const (
+	PING_CLIENT_TO_SERVER uint8 = 0x00
+	PING_SERVER_TO_CLIENT uint8 = 0x01
 )
 
 // Util functions
@@ -82,6 +204,14 @@ func writeString(value string) []uint8 {
 	return buffer[:]
 }
 
+func writeBool(value bool) uint8 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
 func readShort(reader *bufio.Reader) (int16, error) {
 	high, err := reader.ReadByte()
 	if err != nil {
@@ -102,6 +232,36 @@ func writeShort(value int16) []uint8 {
 	return bytes
 }
 
+func readInt(reader *bufio.Reader) (int32, error) {
+	bytes := make([]uint8, 4)
+	for i := range bytes {
+		read, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		bytes[i] = read
+	}
+
+	return int32(binary.BigEndian.Uint32(bytes)), nil
+}
+
+func writeInt(value int32) []uint8 {
+	bytes := make([]uint8, 4)
+	binary.BigEndian.PutUint32(bytes, uint32(value))
+	return bytes
+}
+
+// Writes a fraction as an int of 1/10000 units, as CPE packets send them, clamped to the range of an int
+// since converting a float out of range is implementation-defined. NaN is written as 0
+func writeFixedInt(value float64) []uint8 {
+	scaled := value * 10000
+	if math.IsNaN(scaled) {
+		scaled = 0
+	}
+
+	return writeInt(int32(math.Max(math.MinInt32, math.Min(math.MaxInt32, scaled))))
+}
+
 func readFPShort(reader *bufio.Reader) (FPShort, error) {
 	high, err := reader.ReadByte()
 	if err != nil {
@@ -124,6 +284,46 @@ func readFPShort(reader *bufio.Reader) (FPShort, error) {
 	return FPShort(f), nil
 }
 
+func readBlock(reader *bufio.Reader, extBlocks bool) (Block, error) {
+	if extBlocks {
+		value, err := readShort(reader)
+		return Block(value) & BLOCK_MAX_EXTENDED, err
+	}
+
+	value, err := reader.ReadByte()
+	return Block(value), err
+}
+
+func writeBlock(block Block, extBlocks bool) []uint8 {
+	if extBlocks {
+		return writeShort(int16(block))
+	}
+
+	return []uint8{FallbackBlock(block)}
+}
+
+func readFPInt(reader *bufio.Reader) (FPShort, error) {
+	value, err := readInt(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	return FPShort(float64(value) / 32.0), nil
+}
+
+func writeFPInt(value FPShort) []uint8 {
+	return writeInt(int32(math.Floor(float64(value) * 32.0)))
+}
+
+// Encodes a position as an FPShort, or an FPInt when ExtEntityPositions is in use
+func writePosition(value FPShort, extPositions bool) []uint8 {
+	if extPositions {
+		return writeFPInt(value)
+	}
+
+	return writeFPShort(value)
+}
+
 func writeFPShort(value FPShort) []uint8 {
 	whole := math.Floor(float64(value))
 	decimal := float64(value) - whole
@@ -0,0 +1,23 @@
+package packets
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestWriteFixedInt(t *testing.T) {
+	for value, expected := range map[float64]int32{
+		1.5:          15000,
+		-0.25:        -2500,
+		1e12:         math.MaxInt32,
+		-1e12:        math.MinInt32,
+		math.Inf(1):  math.MaxInt32,
+		math.Inf(-1): math.MinInt32,
+		math.NaN():   0,
+	} {
+		if written := int32(binary.BigEndian.Uint32(writeFixedInt(value))); written != expected {
+			t.Errorf("writeFixedInt(%v) wrote %d, expected %d", value, written, expected)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	. "classicserver/classic/constants"
 	"errors"
+	"io"
 )
 
 //
@@ -15,6 +16,7 @@ type UpstreamPlayerIdentification struct {
 	Version      uint8
 	Username     string
 	Verification string
+	Type         uint8
 }
 
 func ReadPacketID(reader *bufio.Reader) (UpstreamPacketID, error) {
@@ -23,10 +25,22 @@ func ReadPacketID(reader *bufio.Reader) (UpstreamPacketID, error) {
 		return 0xFF, err
 	}
 
-	if id == byte(UPSTREAM_PLAYER_IDENTIFICATION) || id == byte(UPSTREAM_MESSAGE) || id == byte(UPSTREAM_SET_BLOCK) || id == byte(UPSTREAM_SET_POSITION) {
+	switch UpstreamPacketID(id) {
+
+	case UPSTREAM_PLAYER_IDENTIFICATION,
+		UPSTREAM_MESSAGE,
+		UPSTREAM_SET_BLOCK,
+		UPSTREAM_SET_POSITION,
+		UPSTREAM_EXT_INFO,
+		UPSTREAM_EXT_ENTRY,
+		UPSTREAM_PLAYER_CLICK,
+		UPSTREAM_TWO_WAY_PING,
+		UPSTREAM_PLUGIN_MESSAGE:
 		return UpstreamPacketID(id), nil
-	} else {
+
+	default:
 		return 0xFF, errors.New("Unknown Packet ID")
+
 	}
 }
 
@@ -46,8 +60,8 @@ func ReadUpstreamPlayerIdentification(reader *bufio.Reader) (*UpstreamPlayerIden
 		return nil, err
 	}
 
-	// Read empty byte
-	_, err = reader.ReadByte()
+	// Unused byte, CPE_MAGIC for clients supporting CPE
+	clientType, err := reader.ReadByte()
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +73,7 @@ func ReadUpstreamPlayerIdentification(reader *bufio.Reader) (*UpstreamPlayerIden
 		Version:      version,
 		Username:     username,
 		Verification: verification,
+		Type:         clientType,
 	}
 
 	return &packet, nil
@@ -74,10 +89,10 @@ type UpstreamSetBlock struct {
 	Y     int16
 	Z     int16
 	Mode  uint8
-	Block uint8
+	Block Block
 }
 
-func ReadUpstreamSetBlock(reader *bufio.Reader) (*UpstreamSetBlock, error) {
+func ReadUpstreamSetBlock(reader *bufio.Reader, extBlocks bool) (*UpstreamSetBlock, error) {
 	x, err := readShort(reader)
 	if err != nil {
 		return nil, err
@@ -98,7 +113,7 @@ func ReadUpstreamSetBlock(reader *bufio.Reader) (*UpstreamSetBlock, error) {
 		return nil, err
 	}
 
-	block, err := reader.ReadByte()
+	block, err := readBlock(reader, extBlocks)
 	if err != nil {
 		return nil, err
 	}
@@ -131,23 +146,28 @@ type UpstreamSetPosition struct {
 	Pitch    uint8
 }
 
-func ReadUpstreamSetPosition(reader *bufio.Reader) (*UpstreamSetPosition, error) {
+func ReadUpstreamSetPosition(reader *bufio.Reader, extPositions bool) (*UpstreamSetPosition, error) {
+	readPosition := readFPShort
+	if extPositions {
+		readPosition = readFPInt
+	}
+
 	playerId, err := reader.ReadByte()
 	if err != nil {
 		return nil, err
 	}
 
-	x, err := readFPShort(reader)
+	x, err := readPosition(reader)
 	if err != nil {
 		return nil, err
 	}
 
-	y, err := readFPShort(reader)
+	y, err := readPosition(reader)
 	if err != nil {
 		return nil, err
 	}
 
-	z, err := readFPShort(reader)
+	z, err := readPosition(reader)
 	if err != nil {
 		return nil, err
 	}
@@ -208,3 +228,210 @@ func ReadUpstreamMessage(reader *bufio.Reader) (*UpstreamMessage, error) {
 
 	return &packet, nil
 }
+
+//
+// Extension Info
+//
+
+type UpstreamExtInfo struct {
+	UpstreamPacket
+	AppName        string
+	ExtensionCount int16
+}
+
+func ReadUpstreamExtInfo(reader *bufio.Reader) (*UpstreamExtInfo, error) {
+	appName, err := readString(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	extensionCount, err := readShort(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := UpstreamExtInfo{
+		UpstreamPacket: UpstreamPacket{
+			Id: UPSTREAM_EXT_INFO,
+		},
+		AppName:        appName,
+		ExtensionCount: extensionCount,
+	}
+
+	return &packet, nil
+}
+
+//
+// Extension Entry
+//
+
+type UpstreamExtEntry struct {
+	UpstreamPacket
+	ExtName string
+	Version int32
+}
+
+func ReadUpstreamExtEntry(reader *bufio.Reader) (*UpstreamExtEntry, error) {
+	extName, err := readString(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := readInt(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := UpstreamExtEntry{
+		UpstreamPacket: UpstreamPacket{
+			Id: UPSTREAM_EXT_ENTRY,
+		},
+		ExtName: extName,
+		Version: version,
+	}
+
+	return &packet, nil
+}
+
+//
+// Two Way Ping
+//
+
+type UpstreamTwoWayPing struct {
+	UpstreamPacket
+	Direction uint8
+	Data      int16
+}
+
+func ReadUpstreamTwoWayPing(reader *bufio.Reader) (*UpstreamTwoWayPing, error) {
+	direction, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readShort(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	packet := UpstreamTwoWayPing{
+		UpstreamPacket: UpstreamPacket{
+			Id: UPSTREAM_TWO_WAY_PING,
+		},
+		Direction: direction,
+		Data:      data,
+	}
+
+	return &packet, nil
+}
+
+//
+// Player Click
+//
+
+type UpstreamPlayerClick struct {
+	UpstreamPacket
+	Button         uint8
+	Action         uint8
+	Yaw            int16
+	Pitch          int16
+	TargetEntityId int8
+	TargetBlockX   int16
+	TargetBlockY   int16
+	TargetBlockZ   int16
+	TargetFace     uint8
+}
+
+func ReadUpstreamPlayerClick(reader *bufio.Reader) (*UpstreamPlayerClick, error) {
+	button, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	yaw, err := readShort(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	pitch, err := readShort(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	targetEntityId, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	targetBlockX, err := readShort(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	targetBlockY, err := readShort(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	targetBlockZ, err := readShort(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	targetFace, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	packet := UpstreamPlayerClick{
+		UpstreamPacket: UpstreamPacket{
+			Id: UPSTREAM_PLAYER_CLICK,
+		},
+		Button:         button,
+		Action:         action,
+		Yaw:            yaw,
+		Pitch:          pitch,
+		TargetEntityId: int8(targetEntityId),
+		TargetBlockX:   targetBlockX,
+		TargetBlockY:   targetBlockY,
+		TargetBlockZ:   targetBlockZ,
+		TargetFace:     targetFace,
+	}
+
+	return &packet, nil
+}
+
+//
+// Plugin Message
+//
+
+type UpstreamPluginMessage struct {
+	UpstreamPacket
+	Channel uint8
+	Data    [PLUGIN_MESSAGE_SIZE]uint8
+}
+
+func ReadUpstreamPluginMessage(reader *bufio.Reader) (*UpstreamPluginMessage, error) {
+	channel, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	packet := UpstreamPluginMessage{
+		UpstreamPacket: UpstreamPacket{
+			Id: UPSTREAM_PLUGIN_MESSAGE,
+		},
+		Channel: channel,
+	}
+
+	if _, err := io.ReadFull(reader, packet.Data[:]); err != nil {
+		return nil, err
+	}
+
+	return &packet, nil
+}
@@ -0,0 +1,64 @@
+package classic
+
+import (
+	. "classicserver/classic/constants"
+	"classicserver/classic/packets"
+	"fmt"
+	"golang.org/x/exp/slices"
+	"strconv"
+	"strings"
+)
+
+// Parses a comma separated list of block IDs in inventory order, where an empty list keeps the default inventory
+func ParsePalette(value string) ([]Block, error) {
+	palette := []Block{}
+	if strings.TrimSpace(value) == "" {
+		return palette, nil
+	}
+
+	for _, segment := range strings.Split(value, ",") {
+		parsed, err := strconv.ParseUint(strings.TrimSpace(segment), 10, 16)
+		if err != nil || Block(parsed) == BLOCK_AIR || Block(parsed) > BLOCK_MAX_EXTENDED {
+			return nil, fmt.Errorf("Invalid palette block \"%s\"", segment)
+		}
+
+		if !slices.Contains(palette, Block(parsed)) {
+			palette = append(palette, Block(parsed))
+		}
+	}
+
+	return palette, nil
+}
+
+func FormatPalette(palette []Block) string {
+	values := make([]string, len(palette))
+	for i, block := range palette {
+		values[i] = strconv.Itoa(int(block))
+	}
+
+	return strings.Join(values, ",")
+}
+
+// Orders the player's inventory by the world's palette, hiding every other block, or restores the default order
+func (player *Player) SendInventoryOrder(world *World) {
+	if !player.HasExtension(packets.EXT_INVENTORY_ORDER) {
+		return
+	}
+
+	extBlocks := player.HasExtension(packets.EXT_EXTENDED_BLOCKS)
+
+	for block := BLOCK_STONE; block <= BLOCK_OBSIDIAN; block++ {
+		order := block
+		if len(world.Palette) > 0 {
+			order = Block(slices.Index(world.Palette, block) + 1)
+		}
+		player.Write(packets.NewDownstreamSetInventoryOrder(block, order))
+	}
+
+	for i, block := range world.Palette {
+		// Legacy clients only know the standard blocks, which were ordered above
+		if block > BLOCK_OBSIDIAN && extBlocks {
+			player.Write(packets.NewDownstreamSetInventoryOrder(block, Block(i+1)))
+		}
+	}
+}
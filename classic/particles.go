@@ -0,0 +1,305 @@
+package classic
+
+import (
+	. "classicserver/classic/constants"
+	"classicserver/classic/packets"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const PARTICLES_FILENAME = "particles.txt"
+
+// Largest particle size, in blocks, as sizes are sent in 1/32 of a block in one byte
+const MAX_PARTICLE_SIZE = 255.0 / 32
+
+type ParticleEffect struct {
+	Name              string
+	Id                uint8
+	U1                uint8 // Texture region in particles.png, in pixels
+	V1                uint8
+	U2                uint8
+	V2                uint8
+	Red               uint8
+	Green             uint8
+	Blue              uint8
+	FrameCount        uint8
+	ParticleCount     uint8
+	Size              float64 // In blocks
+	SizeVariation     float64
+	Spread            float64
+	Speed             float64
+	Gravity           float64
+	Lifetime          float64 // In seconds
+	LifetimeVariation float64
+	CollideFlags      uint8
+	FullBright        bool
+}
+
+func CreateDefaultParticleEffects() []ParticleEffect {
+	return []ParticleEffect{
+		{
+			Name:              "confetti",
+			Id:                0,
+			U1:                0,
+			V1:                0,
+			U2:                8,
+			V2:                8,
+			Red:               255,
+			Green:             255,
+			Blue:              255,
+			FrameCount:        1,
+			ParticleCount:     40,
+			Size:              0.25,
+			SizeVariation:     0.5,
+			Spread:            1,
+			Speed:             0.5,
+			Gravity:           1,
+			Lifetime:          2,
+			LifetimeVariation: 0.5,
+			CollideFlags:      0,
+			FullBright:        true,
+		},
+	}
+}
+
+// Parses the "key=value" lines of one effect's section
+func parseParticleEffectSetting(effect *ParticleEffect, key string, value string) error {
+	parseUint8 := func(target *uint8) error {
+		parsed, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return err
+		}
+		*target = uint8(parsed)
+		return nil
+	}
+
+	parseFloat := func(target *float64) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*target = parsed
+		return nil
+	}
+
+	parseUint8s := func(targets ...*uint8) error {
+		values := strings.Split(value, ",")
+		if len(values) != len(targets) {
+			return fmt.Errorf("Expected %d comma separated values", len(targets))
+		}
+		for i, target := range targets {
+			parsed, err := strconv.ParseUint(strings.TrimSpace(values[i]), 10, 8)
+			if err != nil {
+				return err
+			}
+			*target = uint8(parsed)
+		}
+		return nil
+	}
+
+	switch key {
+
+	case "id":
+		return parseUint8(&effect.Id)
+
+	case "texture":
+		return parseUint8s(&effect.U1, &effect.V1, &effect.U2, &effect.V2)
+
+	case "tint":
+		return parseUint8s(&effect.Red, &effect.Green, &effect.Blue)
+
+	case "frames":
+		return parseUint8(&effect.FrameCount)
+
+	case "count":
+		return parseUint8(&effect.ParticleCount)
+
+	case "size":
+		var size float64
+		if err := parseFloat(&size); err != nil {
+			return err
+		}
+		if math.IsNaN(size) || size < 0 || size > MAX_PARTICLE_SIZE {
+			return fmt.Errorf("Particle size must be between 0 and %g blocks", MAX_PARTICLE_SIZE)
+		}
+		effect.Size = size
+		return nil
+
+	case "sizeVariation":
+		return parseFloat(&effect.SizeVariation)
+
+	case "spread":
+		return parseFloat(&effect.Spread)
+
+	case "speed":
+		return parseFloat(&effect.Speed)
+
+	case "gravity":
+		return parseFloat(&effect.Gravity)
+
+	case "lifetime":
+		return parseFloat(&effect.Lifetime)
+
+	case "lifetimeVariation":
+		return parseFloat(&effect.LifetimeVariation)
+
+	case "collide":
+		return parseUint8(&effect.CollideFlags)
+
+	case "fullBright":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		effect.FullBright = parsed
+		return nil
+
+	default:
+		return fmt.Errorf("Unknown particle setting \"%s\"", key)
+
+	}
+}
+
+// Loads effects from sections of "key=value" lines, each starting with "[name]"
+func LoadParticleEffects() ([]ParticleEffect, error) {
+	if _, err := os.Stat(PARTICLES_FILENAME); err != nil {
+		if os.IsNotExist(err) {
+			log.Println("Creating new particles.txt...")
+			effects := CreateDefaultParticleEffects()
+			return effects, SaveParticleEffects(effects)
+		} else {
+			return nil, err
+		}
+	}
+
+	contents, err := os.ReadFile(PARTICLES_FILENAME)
+	if err != nil {
+		return nil, err
+	}
+
+	effects := []ParticleEffect{}
+	var effect *ParticleEffect
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			effects = append(effects, ParticleEffect{
+				Name:       strings.ToLower(line[1 : len(line)-1]),
+				Id:         uint8(len(effects)),
+				FrameCount: 1,
+			})
+			effect = &effects[len(effects)-1]
+			continue
+		}
+
+		segments := strings.SplitN(line, "=", 2)
+		if effect == nil || len(segments) != 2 {
+			log.Println("Unable to interpret particle setting \"", line, "\"")
+			continue
+		}
+
+		if err := parseParticleEffectSetting(effect, segments[0], segments[1]); err != nil {
+			log.Println(err)
+			log.Printf("Unable to interpret particle setting \"%s\" value \"%s\"\n", segments[0], segments[1])
+		}
+	}
+
+	return effects, nil
+}
+
+func SaveParticleEffects(effects []ParticleEffect) error {
+	var sb strings.Builder
+	for _, effect := range effects {
+		sb.WriteString(fmt.Sprintf("[%s]\n", effect.Name))
+		sb.WriteString(fmt.Sprintf("id=%d\n", effect.Id))
+		sb.WriteString(fmt.Sprintf("texture=%d,%d,%d,%d\n", effect.U1, effect.V1, effect.U2, effect.V2))
+		sb.WriteString(fmt.Sprintf("tint=%d,%d,%d\n", effect.Red, effect.Green, effect.Blue))
+		sb.WriteString(fmt.Sprintf("frames=%d\n", effect.FrameCount))
+		sb.WriteString(fmt.Sprintf("count=%d\n", effect.ParticleCount))
+		sb.WriteString(fmt.Sprintf("size=%g\n", effect.Size))
+		sb.WriteString(fmt.Sprintf("sizeVariation=%g\n", effect.SizeVariation))
+		sb.WriteString(fmt.Sprintf("spread=%g\n", effect.Spread))
+		sb.WriteString(fmt.Sprintf("speed=%g\n", effect.Speed))
+		sb.WriteString(fmt.Sprintf("gravity=%g\n", effect.Gravity))
+		sb.WriteString(fmt.Sprintf("lifetime=%g\n", effect.Lifetime))
+		sb.WriteString(fmt.Sprintf("lifetimeVariation=%g\n", effect.LifetimeVariation))
+		sb.WriteString(fmt.Sprintf("collide=%d\n", effect.CollideFlags))
+		sb.WriteString(fmt.Sprintf("fullBright=%t\n", effect.FullBright))
+		sb.WriteByte('\n')
+	}
+
+	err := os.WriteFile(PARTICLES_FILENAME, []byte(sb.String()), 0644)
+	return err
+}
+
+func (effect *ParticleEffect) DefinePacket() packets.DownstreamDefineEffect {
+	packet := packets.NewDownstreamDefineEffect(effect.Id)
+	packet.U1 = effect.U1
+	packet.V1 = effect.V1
+	packet.U2 = effect.U2
+	packet.V2 = effect.V2
+	packet.Red = effect.Red
+	packet.Green = effect.Green
+	packet.Blue = effect.Blue
+	packet.FrameCount = effect.FrameCount
+	packet.ParticleCount = effect.ParticleCount
+	packet.Size = effect.packetSize()
+	packet.SizeVariation = effect.SizeVariation
+	packet.Spread = effect.Spread
+	packet.Speed = effect.Speed
+	packet.Gravity = effect.Gravity
+	packet.Lifetime = effect.Lifetime
+	packet.LifetimeVariation = effect.LifetimeVariation
+	packet.CollideFlags = effect.CollideFlags
+	packet.FullBright = effect.FullBright
+	return packet
+}
+
+// Size in 1/32 of a block, clamped to what the packet holds, for effects not loaded from particles.txt
+func (effect *ParticleEffect) packetSize() uint8 {
+	size := effect.Size * 32
+	if math.IsNaN(size) || size <= 0 {
+		return 0
+	} else if size >= math.MaxUint8 {
+		return math.MaxUint8
+	}
+
+	return uint8(size)
+}
+
+func (server *ClassicServer) GetParticleEffect(name string) *ParticleEffect {
+	for i := range server.ParticleEffects {
+		if server.ParticleEffects[i].Name == strings.ToLower(name) {
+			return &server.ParticleEffects[i]
+		}
+	}
+
+	return nil
+}
+
+func (player *Player) SendParticleEffects() {
+	if !player.HasExtension(packets.EXT_CUSTOM_PARTICLES) {
+		return
+	}
+
+	for i := range player.Server.ParticleEffects {
+		player.Write(player.Server.ParticleEffects[i].DefinePacket())
+	}
+}
+
+// Spawns an effect for every player in the world, with particles moving away from the origin
+func (server *ClassicServer) SpawnEffect(world *World, effect *ParticleEffect, x FPShort, y FPShort, z FPShort, originX FPShort, originY FPShort, originZ FPShort) {
+	spawnEffectPacket := packets.NewDownstreamSpawnEffect(effect.Id, x, y, z, originX, originY, originZ)
+	for _, player := range server.PlayersIn(world) {
+		if player.HasExtension(packets.EXT_CUSTOM_PARTICLES) {
+			player.Write(spawnEffectPacket)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package classic
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseParticleSize(t *testing.T) {
+	for value, valid := range map[string]bool{"0.25": true, "0": true, "7.96875": true, "8": false, "-1": false, "NaN": false, "Inf": false} {
+		effect := ParticleEffect{Size: 1}
+		err := parseParticleEffectSetting(&effect, "size", value)
+		if valid && (err != nil || effect.Size == 1) {
+			t.Errorf("Size %s wasn't set: %v", value, err)
+		} else if !valid && (err == nil || effect.Size != 1) {
+			t.Errorf("Size %s was set as %v", value, effect.Size)
+		}
+	}
+}
+
+// Effects made other than from particles.txt, such as by plugins, are clamped instead of wrapping
+func TestParticleEffectPacketSize(t *testing.T) {
+	for size, expected := range map[float64]uint8{0.25: 8, MAX_PARTICLE_SIZE: 255, 8: 255, 100: 255, -1: 0, math.NaN(): 0} {
+		effect := ParticleEffect{Size: size}
+		if packetSize := effect.DefinePacket().Size; packetSize != expected {
+			t.Errorf("Size %v was sent as %d, expected %d", size, packetSize, expected)
+		}
+	}
+}
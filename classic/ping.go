@@ -0,0 +1,55 @@
+package classic
+
+import (
+	"classicserver/classic/packets"
+	"time"
+)
+
+// Number of round trips averaged into Player.Ping
+const PING_SAMPLES = 10
+
+func NewPingTicker() *time.Ticker {
+	return time.NewTicker(time.Second * 5)
+}
+
+func (player *Player) SendPing() {
+	if !player.HasExtension(packets.EXT_TWO_WAY_PING) {
+		return
+	}
+
+	player.pingData++
+	player.pingSent = time.Now()
+	player.Write(packets.NewDownstreamTwoWayPing(packets.PING_SERVER_TO_CLIENT, player.pingData))
+}
+
+func (player *Player) HandlePing(direction uint8, data int16) {
+	if direction == packets.PING_CLIENT_TO_SERVER {
+		player.Write(packets.NewDownstreamTwoWayPing(packets.PING_CLIENT_TO_SERVER, data))
+		return
+	}
+
+	if player.pingSent.IsZero() || data != player.pingData {
+		return
+	}
+
+	player.pingSamples = append(player.pingSamples, time.Since(player.pingSent))
+	if len(player.pingSamples) > PING_SAMPLES {
+		player.pingSamples = player.pingSamples[1:]
+	}
+	player.pingSent = time.Time{}
+
+	total := time.Duration(0)
+	for _, sample := range player.pingSamples {
+		total += sample
+	}
+	player.Ping = total / time.Duration(len(player.pingSamples))
+}
+
+// Formatted average round trip time, or "?" when it has not been measured
+func (player *Player) PingString() string {
+	if len(player.pingSamples) == 0 {
+		return "?"
+	}
+
+	return player.Ping.Round(time.Millisecond).String()
+}
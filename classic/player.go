@@ -6,31 +6,57 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"time"
 )
 
+const DEFAULT_MODEL = "humanoid"
+
 type Player struct {
-	Server   *ClassicServer
-	Id       int8
-	Conn     net.Conn
-	Username string
-	Mode     PlayerMode
-	X        FPShort
-	Y        FPShort
-	Z        FPShort
-	Yaw      uint8
-	Pitch    uint8
+	Server     *ClassicServer
+	Id         int8
+	Conn       net.Conn
+	Username   string
+	Mode       PlayerMode
+	X          FPShort
+	Y          FPShort
+	Z          FPShort
+	Yaw        uint8
+	Pitch      uint8
+	Extensions map[string]int32
+	Ping       time.Duration
+	Model      string
+	World      *World
+
+	pingData    int16
+	pingSent    time.Time
+	pingSamples []time.Duration
+
+	clickCallback PlayerClickHandler
 }
 
 func NewPlayer(server *ClassicServer, id int8, conn net.Conn, username string) *Player {
 	return &Player{
-		Server:   server,
-		Id:       id,
-		Conn:     conn,
-		Username: username,
+		Server:     server,
+		Id:         id,
+		Conn:       conn,
+		Username:   username,
+		Extensions: make(map[string]int32),
+		Model:      DEFAULT_MODEL,
 	}
 }
 
 func (player *Player) Write(packet packets.DownstreamPacketInterface) error {
+	if player.Conn == nil {
+		return net.ErrClosed
+	}
+
+	if positionPacket, ok := packet.(packets.ExtPositionsPacket); ok {
+		packet = positionPacket.WithExtPositions(player.HasExtension(packets.EXT_ENTITY_POSITIONS))
+	}
+	if blocksPacket, ok := packet.(packets.ExtBlocksPacket); ok {
+		packet = blocksPacket.WithExtBlocks(player.HasExtension(packets.EXT_EXTENDED_BLOCKS))
+	}
+
 	err := packet.Write(player.Conn)
 	if err != nil {
 		log.Println(err)
@@ -41,12 +67,45 @@ func (player *Player) Write(packet packets.DownstreamPacketInterface) error {
 }
 
 func (player *Player) SendMessage(message string, args ...any) {
-	player.Write(packets.NewDownstreamMessage(-1, fmt.Sprintf(message, args...)))
+	player.WriteMessage(-1, fmt.Sprintf(message, args...))
+}
+
+// Writes a chat message with its colour codes sanitized for the player's client
+func (player *Player) WriteMessage(senderId int8, message string) error {
+	message = player.Server.SanitizeColors(message, player.HasExtension(packets.EXT_TEXT_COLORS))
+	if len(message) == 0 {
+		return nil
+	}
+
+	return player.Write(packets.NewDownstreamMessage(senderId, message))
 }
 
 func (player *Player) SetMode(mode PlayerMode) {
 	player.Mode = mode
 	player.Write(packets.NewDownstreamUpdatePlayerMode(mode))
+	player.SendHotKeys()
+}
+
+// Changes the model the player appears as, including to themselves
+func (player *Player) SetModel(model string) {
+	player.Model = model
+	for _, other := range player.Server.PlayersIn(player.World) {
+		other.SendModel(player)
+	}
+}
+
+// Sends the model of a spawned player, which is only needed when it differs from the default
+func (player *Player) SendModel(of *Player) {
+	if !player.HasExtension(packets.EXT_CHANGE_MODEL) {
+		return
+	}
+
+	entityId := of.Id
+	if of.Id == player.Id {
+		entityId = -1
+	}
+
+	player.Write(packets.NewDownstreamChangeModel(entityId, of.Model))
 }
 
 func (player *Player) Teleport(x FPShort, y FPShort, z FPShort, yaw uint8, pitch uint8) {
@@ -59,6 +118,35 @@ func (player *Player) Teleport(x FPShort, y FPShort, z FPShort, yaw uint8, pitch
 	player.Write(packets.NewDownstreamSetPosition(-1, x, y, z, yaw, pitch))
 }
 
+// Sets the player's velocity in blocks per tick, returning false if their client cannot be launched
+func (player *Player) Launch(x float64, y float64, z float64) bool {
+	return player.SetVelocity(x, y, z, packets.VELOCITY_SET, packets.VELOCITY_SET, packets.VELOCITY_SET)
+}
+
+// Adds to the player's velocity in blocks per tick, returning false if their client cannot be pushed
+func (player *Player) Push(x float64, y float64, z float64) bool {
+	return player.SetVelocity(x, y, z, packets.VELOCITY_ADD, packets.VELOCITY_ADD, packets.VELOCITY_ADD)
+}
+
+func (player *Player) SetVelocity(x float64, y float64, z float64, xMode uint8, yMode uint8, zMode uint8) bool {
+	if !player.HasExtension(packets.EXT_VELOCITY_CONTROL) {
+		return false
+	}
+
+	player.Write(packets.NewDownstreamVelocityControl(x, y, z, xMode, yMode, zMode))
+	return true
+}
+
+// Moves where the player respawns without teleporting them, returning false if their client cannot be sent a spawnpoint
+func (player *Player) SetSpawnpoint(x FPShort, y FPShort, z FPShort, yaw uint8, pitch uint8) bool {
+	if !player.HasExtension(packets.EXT_SET_SPAWNPOINT) {
+		return false
+	}
+
+	player.Write(packets.NewDownstreamSetSpawnpoint(x, y, z, yaw, pitch))
+	return true
+}
+
 func (player *Player) Kick(reason string) {
 	player.Write(packets.NewDownstreamDisconnectPlayer(reason))
 	player.Disconnect()
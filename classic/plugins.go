@@ -0,0 +1,66 @@
+package classic
+
+import (
+	"bytes"
+	"classicserver/classic/packets"
+	"fmt"
+)
+
+type PluginMessageEvent struct {
+	Player  *Player
+	Channel uint8
+	Data    [packets.PLUGIN_MESSAGE_SIZE]uint8
+}
+
+type PluginMessageHandler func(event *PluginMessageEvent)
+
+// Registers a handler run on the main loop for every message a client plugin sends on the channel
+func (server *ClassicServer) AddPluginMessageHandler(channel uint8, handler PluginMessageHandler) {
+	if server.pluginMessageHandlers == nil {
+		server.pluginMessageHandlers = make(map[uint8][]PluginMessageHandler)
+	}
+
+	server.pluginMessageHandlers[channel] = append(server.pluginMessageHandlers[channel], handler)
+}
+
+func (server *ClassicServer) HandlePluginMessage(player *Player, packet packets.UpstreamPluginMessage) {
+	event := &PluginMessageEvent{
+		Player:  player,
+		Channel: packet.Channel,
+		Data:    packet.Data,
+	}
+
+	for _, handler := range server.pluginMessageHandlers[packet.Channel] {
+		handler(event)
+	}
+}
+
+// Data with the zero padding removed, for plugins sending text
+func (event *PluginMessageEvent) String() string {
+	return string(bytes.TrimRight(event.Data[:], "\x00"))
+}
+
+// Sends up to 64 bytes to the client plugin listening on the channel, zero padding the rest
+func (player *Player) SendPluginMessage(channel uint8, data []byte) error {
+	if !player.HasExtension(packets.EXT_PLUGIN_MESSAGES) {
+		return fmt.Errorf("%s does not support %s", player.Username, packets.EXT_PLUGIN_MESSAGES)
+	}
+
+	if len(data) > packets.PLUGIN_MESSAGE_SIZE {
+		return fmt.Errorf("Plugin message of %d bytes exceeds %d bytes", len(data), packets.PLUGIN_MESSAGE_SIZE)
+	}
+
+	var payload [packets.PLUGIN_MESSAGE_SIZE]uint8
+	copy(payload[:], data)
+
+	return player.Write(packets.NewDownstreamPluginMessage(channel, payload))
+}
+
+// Sends the message to every player whose client supports plugin messages
+func (server *ClassicServer) BroadcastPluginMessage(channel uint8, data []byte) {
+	for _, player := range server.Players {
+		if player.HasExtension(packets.EXT_PLUGIN_MESSAGES) {
+			player.SendPluginMessage(channel, data)
+		}
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"net"
 	"regexp"
 	"strings"
+	"time"
 
 	"golang.org/x/exp/slices"
 )
@@ -19,12 +21,26 @@ import (
 type ClassicServer struct {
 	Listener        net.Listener
 	Players         map[int8]*Player
-	World           *World
+	Worlds          map[string]*World
 	PlayerIdChannel chan int8
 	Settings        *Settings
 	Salt            string
 	OPs             []string
 	Bans            []string
+	HotKeys         []HotKey
+	ParticleEffects []ParticleEffect
+	TextColors      map[uint8]TextColor
+
+	clickHandlers []PlayerClickHandler
+
+	pluginMessageHandlers map[uint8][]PluginMessageHandler
+
+	mainLoopTasks chan func()
+
+	// Lowercased names of worlds being created, deleted or renamed in the background, which can't be used until done
+	pendingWorlds map[string]bool
+	// Functions waiting for a world being loaded in the background, by its lowercased name
+	loadingWorlds map[string][]func(world *World, err error)
 }
 
 const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
@@ -56,8 +72,11 @@ func NewClassicServer() (*ClassicServer, error) {
 	salt := generateSalt()
 	log.Println("Salt:", salt)
 
-	world, err := LoadWorld(settings.WorldX, settings.WorldY, settings.WorldZ)
-	if err != nil {
+	if settings.WorldX <= 0 || settings.WorldY <= 0 || settings.WorldZ <= 0 {
+		return nil, fmt.Errorf("Invalid world dimensions %dx%dx%d", settings.WorldX, settings.WorldY, settings.WorldZ)
+	}
+
+	if err := migrateLegacyWorld(settings.MainWorld); err != nil {
 		return nil, err
 	}
 
@@ -71,6 +90,25 @@ func NewClassicServer() (*ClassicServer, error) {
 		return nil, err
 	}
 
+	hotKeys, err := LoadHotKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	particleEffects, err := LoadParticleEffects()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := LoadBlockFallbacks(); err != nil {
+		return nil, err
+	}
+
+	textColors, err := LoadTextColors()
+	if err != nil {
+		return nil, err
+	}
+
 	log.Printf("Allocating for %d players\n", settings.PlayerCount)
 	playerIdChannel := make(chan int8, settings.PlayerCount)
 	for i := uint8(0); i < settings.PlayerCount && i < 128; i++ {
@@ -80,17 +118,40 @@ func NewClassicServer() (*ClassicServer, error) {
 	server := ClassicServer{
 		Listener:        listener,
 		Players:         players,
-		World:           world,
+		Worlds:          make(map[string]*World),
 		PlayerIdChannel: playerIdChannel,
 		Settings:        settings,
 		Salt:            salt,
 		OPs:             ops,
 		Bans:            bans,
+		HotKeys:         hotKeys,
+		ParticleEffects: particleEffects,
+		TextColors:      textColors,
+
+		mainLoopTasks: make(chan func(), 16),
+		pendingWorlds: make(map[string]bool),
+		loadingWorlds: make(map[string][]func(world *World, err error)),
+	}
+
+	world, err := server.loadWorld(settings.MainWorld)
+	if err != nil {
+		return nil, err
+	}
+
+	if world.RequiresExtPositions() {
+		log.Printf("World is larger than %d blocks; only clients supporting ExtEntityPositions can join\n", LEGACY_MAX_WORLD_SIZE)
 	}
 
 	return &server, nil
 }
 
+// Queues the task to run on the main loop, so background work such as a save can report back to players
+func (server *ClassicServer) runOnMainLoop(task func()) {
+	go func() {
+		server.mainLoopTasks <- task
+	}()
+}
+
 func (server *ClassicServer) GetPlayer(playerId int8) *Player {
 	return server.Players[playerId]
 }
@@ -141,6 +202,11 @@ func (server *ClassicServer) ConnectPlayer(player *Player, verification string)
 		return deny("You have been banned")
 	}
 
+	world := server.MainWorld()
+	if world.RequiresExtPositions() && !player.HasExtension(packets.EXT_ENTITY_POSITIONS) {
+		return deny("This world requires a client supporting ExtEntityPositions")
+	}
+
 	mode := MODE_NORMAL
 	if slices.Contains(server.OPs, player.Username) {
 		mode = MODE_OP
@@ -149,40 +215,67 @@ func (server *ClassicServer) ConnectPlayer(player *Player, verification string)
 
 	serverIdentificationPacket := packets.NewDownstreamServerIdentification(
 		server.Settings.Name,
-		server.Settings.MOTD,
+		server.MOTD(world),
 		mode,
 	)
 	if err := player.Write(serverIdentificationPacket); err != nil {
 		return err
 	}
 
-	if err := server.World.SendWorld(player); err != nil {
+	if err := world.SendWorld(player); err != nil {
 		return err
 	}
 
+	player.World = world
+	world.lastActive = time.Now()
+
+	player.SendTextColors()
+	player.SendWorldSettings(world)
+	player.SendHotKeys()
+	player.SendParticleEffects()
+
 	joinMsg := fmt.Sprintf("%s has joined", player.Username)
 	log.Println(joinMsg)
 	server.BroadcastMessage(-1, joinMsg)
 
+	player.X = FPShort(world.SpawnX)
+	player.Y = FPShort(world.SpawnY)
+	player.Z = FPShort(world.SpawnZ)
+	player.Yaw = world.SpawnYaw
+	player.Pitch = world.SpawnPitch
+	server.spawnPlayer(player)
+
+	return nil
+}
+
+// Sends the per-world hacks, environment and inventory order
+func (player *Player) SendWorldSettings(world *World) {
+	player.SendHackControl(world)
+	player.SendEnvironment(world)
+	player.SendInventoryOrder(world)
+}
+
+// Spawns the player at their position for everyone in their world, and everyone else there for the player
+func (server *ClassicServer) spawnPlayer(player *Player) {
 	spawnPlayerPacket := packets.NewDownstreamSpawnPlayer(
 		player.Id,
 		player.Username,
-		FPShort(server.World.SpawnX),
-		FPShort(server.World.SpawnY),
-		FPShort(server.World.SpawnZ),
-		server.World.SpawnYaw,
-		server.World.SpawnPitch,
+		player.X,
+		player.Y,
+		player.Z,
+		player.Yaw,
+		player.Pitch,
 	)
-	for _, other := range server.Players {
+	for _, other := range server.PlayersIn(player.World) {
 		if other.Id == player.Id {
 			other.Write(packets.NewDownstreamSpawnPlayer(
 				-1,
 				player.Username,
-				FPShort(server.World.SpawnX),
-				FPShort(server.World.SpawnY),
-				FPShort(server.World.SpawnZ),
-				server.World.SpawnYaw,
-				server.World.SpawnPitch,
+				player.X,
+				player.Y,
+				player.Z,
+				player.Yaw,
+				player.Pitch,
 			))
 		} else {
 			player.Write(
@@ -196,31 +289,52 @@ func (server *ClassicServer) ConnectPlayer(player *Player, verification string)
 					other.Pitch,
 				),
 			)
+			if other.Model != DEFAULT_MODEL {
+				player.SendModel(other)
+			}
+
 			other.Write(spawnPlayerPacket)
+			if player.Model != DEFAULT_MODEL {
+				other.SendModel(player)
+			}
 		}
 	}
+}
 
-	return nil
+// Swaps in a new copy of a world, such as an imported one, and sends everyone in it to the new copy.
+// Players whose client can't move around the new world are kicked
+func (server *ClassicServer) ReplaceWorld(old *World, world *World) {
+	// Wait for any save of the old copy in progress, and skip those yet to start, so they can't
+	// write over the new one
+	old.saveLock.Lock()
+	old.savedSnapshot = math.MaxUint64
+	old.saveLock.Unlock()
+
+	world.Name = old.Name
+	world.lastActive = time.Now()
+	world.lastBackup = old.lastBackup
+	server.Worlds[world.Name] = world
+
+	for _, player := range server.PlayersIn(old) {
+		if err := server.ChangeWorld(player, world); err != nil {
+			player.Kick(err.Error())
+		}
+	}
 }
 
 func (server *ClassicServer) SetPosition(player *Player, x FPShort, y FPShort, z FPShort, yaw uint8, pitch uint8) {
 	setPositionPacket := packets.NewDownstreamSetPosition(player.Id, x, y, z, yaw, pitch)
-	for _, other := range server.Players {
+	for _, other := range server.PlayersIn(player.World) {
 		if other.Id != player.Id {
 			other.Write(setPositionPacket)
 		}
 	}
 }
 
-func (server *ClassicServer) SetBlock(x int16, y int16, z int16, block Block) {
-	server.World.SetBlock(x, y, z, block)
-
-	setBlockPacket := packets.NewDownstreamSetBlock(x, y, z, block)
-	for _, player := range server.Players {
-		player.Write(setBlockPacket)
-	}
-
-	server.World.UpdateBlock(server, x, y, z)
+func (server *ClassicServer) SetBlock(world *World, x int16, y int16, z int16, block Block) {
+	world.SetBlock(x, y, z, block)
+	world.queueBlockChange(x, y, z, block)
+	world.UpdateBlock(server, x, y, z)
 }
 
 func (server *ClassicServer) Ban(username string, reason string) {
@@ -281,12 +395,11 @@ func (server *ClassicServer) RemoveOP(username string) {
 }
 
 func (server *ClassicServer) BroadcastMessage(senderId int8, message string) {
-	despawnPacket := packets.NewDownstreamMessage(senderId, message)
 	for _, other := range server.Players {
 		if other.Id == senderId {
-			other.Write(packets.NewDownstreamMessage(-1, message))
+			other.WriteMessage(-1, message)
 		} else {
-			other.Write(despawnPacket)
+			other.WriteMessage(senderId, message)
 		}
 	}
 }
@@ -312,11 +425,17 @@ func (server *ClassicServer) DisconnectPlayer(player *Player, silent bool) {
 		despawnPacket := packets.NewDownstreamDespawnPlayer(player.Id)
 		for _, other := range player.Server.Players {
 			if other.Id != player.Id {
-				other.Write(despawnPacket)
+				if other.World == player.World {
+					other.Write(despawnPacket)
+				}
 				other.SendMessage(leaveMsg)
 			}
 		}
 	}
 
+	if player.World != nil {
+		player.World.lastActive = time.Now()
+	}
+
 	delete(server.Players, player.Id)
 }
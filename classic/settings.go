@@ -22,6 +22,14 @@ type Settings struct {
 	WorldY      int16
 	WorldZ      int16
 	PlayerCount uint8
+	ChatColors  string
+
+	MainWorld          string // World new players join
+	WorldUnloadMinutes int    // Time a world is kept loaded without players, 0 to keep every world loaded
+
+	BackupMinutes    int // Time between backups taken when the world saves, 0 to disable
+	BackupKeepHourly int // Hours for which an hourly backup is kept
+	BackupKeepDaily  int // Days for which a daily backup is kept
 }
 
 func CreateDefaultSettings() *Settings {
@@ -37,6 +45,14 @@ func CreateDefaultSettings() *Settings {
 		WorldY:      256,
 		WorldZ:      256,
 		PlayerCount: 128,
+		ChatColors:  CHAT_COLORS_OP,
+
+		MainWorld:          "main",
+		WorldUnloadMinutes: 10,
+
+		BackupMinutes:    60,
+		BackupKeepHourly: 24,
+		BackupKeepDaily:  30,
 	}
 }
 
@@ -147,6 +163,48 @@ func LoadSettings() (*Settings, error) {
 				settings.PlayerCount = uint8(parsed)
 			}
 
+		case "chatColors":
+			if value != CHAT_COLORS_ALL && value != CHAT_COLORS_OP && value != CHAT_COLORS_NONE {
+				log.Printf("Unable to interpret setting \"chatColors\" value \"%s\"\n", value)
+			} else {
+				settings.ChatColors = value
+			}
+
+		case "mainWorld":
+			if !ValidWorldName(value) {
+				log.Printf("Unable to interpret setting \"mainWorld\" value \"%s\"\n", value)
+			} else {
+				settings.MainWorld = value
+			}
+
+		case "worldUnloadMinutes":
+			if parsed, err := strconv.Atoi(value); err != nil || parsed < 0 {
+				log.Printf("Unable to interpret setting \"worldUnloadMinutes\" value \"%s\"\n", value)
+			} else {
+				settings.WorldUnloadMinutes = parsed
+			}
+
+		case "backupMinutes":
+			if parsed, err := strconv.Atoi(value); err != nil || parsed < 0 {
+				log.Printf("Unable to interpret setting \"backupMinutes\" value \"%s\"\n", value)
+			} else {
+				settings.BackupMinutes = parsed
+			}
+
+		case "backupKeepHourly":
+			if parsed, err := strconv.Atoi(value); err != nil || parsed < 0 {
+				log.Printf("Unable to interpret setting \"backupKeepHourly\" value \"%s\"\n", value)
+			} else {
+				settings.BackupKeepHourly = parsed
+			}
+
+		case "backupKeepDaily":
+			if parsed, err := strconv.Atoi(value); err != nil || parsed < 0 {
+				log.Printf("Unable to interpret setting \"backupKeepDaily\" value \"%s\"\n", value)
+			} else {
+				settings.BackupKeepDaily = parsed
+			}
+
 		}
 	}
 
@@ -166,6 +224,12 @@ func SaveSettings(settings *Settings) error {
 	sb.WriteString(fmt.Sprintf("worldY=%d\n", settings.WorldY))
 	sb.WriteString(fmt.Sprintf("worldZ=%d\n", settings.WorldZ))
 	sb.WriteString(fmt.Sprintf("playerCount=%d\n", settings.PlayerCount))
+	sb.WriteString(fmt.Sprintf("chatColors=%s\n", settings.ChatColors))
+	sb.WriteString(fmt.Sprintf("mainWorld=%s\n", settings.MainWorld))
+	sb.WriteString(fmt.Sprintf("worldUnloadMinutes=%d\n", settings.WorldUnloadMinutes))
+	sb.WriteString(fmt.Sprintf("backupMinutes=%d\n", settings.BackupMinutes))
+	sb.WriteString(fmt.Sprintf("backupKeepHourly=%d\n", settings.BackupKeepHourly))
+	sb.WriteString(fmt.Sprintf("backupKeepDaily=%d\n", settings.BackupKeepDaily))
 
 	err := os.WriteFile(SETTINGS_FILENAME, []byte(sb.String()), 0644)
 	return err
@@ -3,19 +3,33 @@ package classic
 import (
 	"bytes"
 	. "classicserver/classic/constants"
+	"classicserver/classic/nbt"
 	"classicserver/classic/packets"
+	"compress/flate"
 	"compress/gzip"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"log"
-	"math"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const WORLD_FILENAME string = "world.gw"
 
+// Holds a directory for each world, named after it
+const WORLDS_DIRECTORY = "worlds"
+
+// Largest dimension whose positions fit in an FPShort
+const LEGACY_MAX_WORLD_SIZE int16 = 1023
+
 type World struct {
+	Name       string // Changed only with saveLock held, so background saves use the current name
+	ReadOnly   bool   // Shown to players, such as a backup, but never changed or saved
 	SizeX      int16
 	SizeY      int16
 	SizeZ      int16
@@ -24,21 +38,117 @@ type World struct {
 	SpawnZ     float64
 	SpawnYaw   uint8
 	SpawnPitch uint8
-	Blocks     [][][]Block // [Y][Z][X]
+	Hacks      HackSettings
+	Env        EnvSettings
+	Palette    []Block // Inventory order, empty for the default inventory
+	Blocks     []Block // Ordered by Y, then Z, then X, as in level data; see BlockIndex
+
+	ExtraMetadata nbt.Compound // ClassicWorld metadata kept for export, such as block definitions
+
+	changes      []BlockChange // Journal of changes the cached level data doesn't include yet
+	changesStart uint64        // Number of the first change in the journal
+	level        levelCache
+
+	pendingBlocks       []BlockChange
+	pendingBlockIndices map[int32]int
+
+	lastActive time.Time // When a player last left, for unloading idle worlds
+	lastBackup time.Time
+	unloading  bool // Being saved before it is unloaded
+
+	dirty atomic.Bool // Blocks or settings have changed since the world was last saved
+
+	snapshots     uint64     // Snapshots taken for saving, counted on the main loop
+	saveLock      sync.Mutex // Held while saving a snapshot, and guarding savedSnapshot
+	savedSnapshot uint64     // Newest snapshot saved, so an older one finishing later isn't written over it
 }
 
-func NewWorld(sizeX int16, sizeY int16, sizeZ int16) *World {
+// Copies the world so it can be saved or exported in the background while the original keeps changing
+func (world *World) Snapshot() *World {
+	blocks := make([]Block, len(world.Blocks))
+	copy(blocks, world.Blocks)
+
+	return &World{
+		Name:          world.Name,
+		ReadOnly:      world.ReadOnly,
+		SizeX:         world.SizeX,
+		SizeY:         world.SizeY,
+		SizeZ:         world.SizeZ,
+		SpawnX:        world.SpawnX,
+		SpawnY:        world.SpawnY,
+		SpawnZ:        world.SpawnZ,
+		SpawnYaw:      world.SpawnYaw,
+		SpawnPitch:    world.SpawnPitch,
+		Hacks:         world.Hacks,
+		Env:           world.Env,
+		Palette:       append([]Block(nil), world.Palette...),
+		Blocks:        blocks,
+		ExtraMetadata: world.ExtraMetadata, // Replaced rather than changed in place
+	}
+}
 
-	blocks := make([][][]Block, sizeY)
-	for y := int16(0); y < sizeY; y++ {
-		blocks[y] = make([][]Block, sizeZ)
-		for z := int16(0); z < sizeZ; z++ {
-			blocks[y][z] = make([]Block, sizeX)
-			for x := int16(0); x < sizeX; x++ {
-				blocks[y][z][x] = BLOCK_AIR
+// Returned to a background save's done function when a newer snapshot was saved first, or the world was deleted
+var ErrSaveSkipped = errors.New("World save skipped for a newer save")
+
+// Saves a snapshot of the world in the background, then calls done, if it isn't nil, from the
+// background with the result. done runs before any later save, so it can back up the snapshot.
+// The world is marked dirty again if the save fails
+func (world *World) SaveInBackground(done func(snapshot *World, err error)) {
+	world.dirty.Store(false)
+	snapshot := world.Snapshot()
+	world.snapshots++
+	number := world.snapshots
+
+	go func() {
+		world.saveLock.Lock()
+		defer world.saveLock.Unlock()
+
+		// Skip snapshots older than one already saved, and saves of a deleted world
+		if number <= world.savedSnapshot {
+			if done != nil {
+				done(snapshot, ErrSaveSkipped)
 			}
+			return
 		}
-	}
+
+		snapshot.Name = world.Name // In case the world was renamed meanwhile
+		err := SaveWorld(snapshot)
+		if err == nil {
+			world.savedSnapshot = number
+		} else {
+			world.dirty.Store(true)
+		}
+
+		if done != nil {
+			done(snapshot, err)
+		}
+	}()
+}
+
+// Whether blocks or settings have changed since the world was last saved
+func (world *World) Dirty() bool {
+	return world.dirty.Load()
+}
+
+// Notes a change to the world's settings, such as its hacks, so the next save includes it.
+// Settings are only written along with the blocks, so an older snapshot can't write over them
+func (world *World) markDirty() {
+	world.dirty.Store(true)
+}
+
+// Path of one of the world's files, inside its directory
+func (world *World) Path(filename string) string {
+	return filepath.Join(WORLDS_DIRECTORY, world.Name, filename)
+}
+
+// Blocks of a world of the given size, all air
+func newBlocks(sizeX int16, sizeY int16, sizeZ int16) []Block {
+	return make([]Block, int(sizeX)*int(sizeY)*int(sizeZ))
+}
+
+func NewWorld(sizeX int16, sizeY int16, sizeZ int16) *World {
+
+	blocks := newBlocks(sizeX, sizeY, sizeZ)
 
 	world := &World{
 		SizeX:      sizeX,
@@ -49,44 +159,31 @@ func NewWorld(sizeX int16, sizeY int16, sizeZ int16) *World {
 		SpawnZ:     0.0,
 		SpawnYaw:   0,
 		SpawnPitch: 0,
+		Hacks:      DefaultHackSettings(),
+		Env:        DefaultEnvSettings(),
 		Blocks:     blocks,
 	}
 
-	GenerateWorld(world)
-
 	return world
 }
 
-func GenerateWorld(world *World) {
-	for y := int16(0); y <= (world.SizeY/2)+1; y++ {
-		top := y == (world.SizeY/2)+1
-		for z := int16(0); z < world.SizeZ; z++ {
-			for x := int16(0); x < world.SizeX; x++ {
-				if top {
-					world.Blocks[y][z][x] = BLOCK_GRASS_BLOCK
-				} else {
-					world.Blocks[y][z][x] = BLOCK_DIRT
-				}
-			}
-		}
+// Loads the named world, generating one of the given size if it doesn't exist
+func LoadWorld(name string, sizeX int16, sizeY int16, sizeZ int16) (*World, error) {
+	world := NewWorld(sizeX, sizeY, sizeZ)
+	world.Name = name
+	if err := LoadWorldMeta(world); err != nil {
+		return nil, err
 	}
 
-	world.SpawnX = math.Floor(float64(world.SizeX)/2.0) + 0.5
-	world.SpawnZ = math.Floor(float64(world.SizeZ)/2.0) + 0.5
-	for y := world.SizeY - 1; y >= 0; y-- {
-		if world.GetBlock(int16(world.SpawnX), y, int16(world.SpawnZ)) != BLOCK_AIR {
-			world.SpawnY = float64(y) + 2
-			return
-		}
+	if err := LoadWorldExtraMetadata(world); err != nil {
+		return nil, err
 	}
-}
 
-func LoadWorld(sizeX int16, sizeY int16, sizeZ int16) (*World, error) {
-	world := NewWorld(sizeX, sizeY, sizeZ)
-	if _, err := os.Stat(WORLD_FILENAME); err != nil {
+	path := world.Path(WORLD_FILENAME)
+	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
-			log.Println("Generating new world...")
-			GenerateWorld(world)
+			log.Printf("Generating new world %s...\n", name)
+			GenerateFlat(world, 0)
 
 			if err := SaveWorld(world); err != nil {
 				return nil, err
@@ -97,63 +194,22 @@ func LoadWorld(sizeX int16, sizeY int16, sizeZ int16) (*World, error) {
 			return nil, err
 		}
 	}
-	log.Println("Loading world...")
+	log.Printf("Loading world %s...\n", name)
 
-	contents, err := os.ReadFile(WORLD_FILENAME)
+	contents, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var b bytes.Buffer
-	b.Write(contents)
-	gz, err := gzip.NewReader(&b)
-	defer gz.Close()
-
+	version, err := decodeWorld(world, contents)
 	if err != nil {
-		return nil, err
-	}
-
-	readShort := func() int16 {
-		bytes := make([]uint8, 2)
-		gz.Read(bytes)
-		return int16(binary.BigEndian.Uint16(bytes))
+		return nil, fmt.Errorf("Unable to load %s: %w", path, err)
 	}
 
-	world.SizeX = readShort()
-	world.SizeY = readShort()
-	world.SizeZ = readShort()
-
-	readFloat := func() float64 {
-		bytes := make([]uint8, 8)
-		gz.Read(bytes)
-		i64 := binary.BigEndian.Uint64(bytes)
-		return math.Float64frombits(i64)
-	}
-
-	world.SpawnX = readFloat()
-	world.SpawnY = readFloat()
-	world.SpawnZ = readFloat()
-
-	readByte := func() uint8 {
-		bytes := make([]uint8, 1)
-		gz.Read(bytes)
-		return bytes[0]
-	}
-
-	world.SpawnYaw = readByte()
-	world.SpawnPitch = readByte()
-
-	for y := int16(0); y < world.SizeY; y++ {
-		for z := int16(0); z < world.SizeZ; z++ {
-			for x := int16(0); x < world.SizeX; x++ {
-				b := make([]uint8, 1)
-				if _, err := gz.Read(b); err != nil {
-					if x != world.SizeX-1 || y != world.SizeY-1 || z != world.SizeZ-1 || err != io.EOF {
-						return nil, err
-					}
-				}
-				world.SetBlock(x, y, z, b[0])
-			}
+	if version < WORLD_VERSION {
+		log.Printf("Migrating %s from version %d to %d...\n", path, version, WORLD_VERSION)
+		if err := SaveWorld(world); err != nil {
+			return nil, err
 		}
 	}
 
@@ -161,45 +217,26 @@ func LoadWorld(sizeX int16, sizeY int16, sizeZ int16) (*World, error) {
 }
 
 func SaveWorld(world *World) error {
-	log.Println("-- Saving world...")
-	var b bytes.Buffer
-	gz := gzip.NewWriter(&b)
-
-	writeShort := func(val int16) {
-		bytes := make([]uint8, 2)
-		binary.BigEndian.PutUint16(bytes, uint16(val))
-		gz.Write(bytes)
+	if world.ReadOnly {
+		return fmt.Errorf("World %s is read-only", world.Name)
 	}
 
-	writeShort(world.SizeX)
-	writeShort(world.SizeY)
-	writeShort(world.SizeZ)
+	log.Printf("-- Saving world %s...\n", world.Name)
 
-	writeFloat := func(val float64) {
-		i64 := math.Float64bits(val)
-		bytes := make([]uint8, 8)
-		binary.BigEndian.PutUint64(bytes, i64)
-		gz.Write(bytes)
+	data, err := encodeWorld(world)
+	if err != nil {
+		return err
 	}
 
-	writeFloat(world.SpawnX)
-	writeFloat(world.SpawnY)
-	writeFloat(world.SpawnZ)
-
-	gz.Write([]byte{world.SpawnYaw})
-	gz.Write([]byte{world.SpawnPitch})
-
-	if err := gzipWorld(world, gz); err != nil {
+	if err := writeFileAtomic(world.Path(WORLD_FILENAME), data); err != nil {
 		return err
 	}
 
-	if err := gz.Close(); err != nil {
+	if err := SaveWorldMeta(world); err != nil {
 		return err
 	}
 
-	data := b.Bytes()
-
-	if err := os.WriteFile(WORLD_FILENAME, data, 0644); err != nil {
+	if err := SaveWorldExtraMetadata(world); err != nil {
 		return err
 	}
 
@@ -208,48 +245,128 @@ func SaveWorld(world *World) error {
 	return nil
 }
 
-func gzipWorld(world *World, gz *gzip.Writer) error {
-	for y := int16(0); y < world.SizeY; y++ {
-		for z := int16(0); z < world.SizeZ; z++ {
-			for x := int16(0); x < world.SizeX; x++ {
-				if _, err := gz.Write([]uint8{world.Blocks[y][z][x]}); err != nil {
-					return err
-				}
-			}
+// Writes the lower 8 bits of each block, or the fallback blocks for clients without ExtendedBlocks
+func writeWorldBlocks(world *World, w io.Writer, extBlocks bool) error {
+	data := make([]uint8, len(world.Blocks))
+	for i, block := range world.Blocks {
+		if extBlocks {
+			data[i] = uint8(block)
+		} else {
+			data[i] = FallbackBlock(block)
 		}
 	}
 
+	_, err := w.Write(data)
+	return err
+}
+
+func writeWorldUpperBlocks(world *World, w io.Writer) error {
+	data := make([]uint8, len(world.Blocks))
+	for i, block := range world.Blocks {
+		data[i] = uint8(block >> 8)
+	}
+
+	_, err := w.Write(data)
+	return err
+}
+
+func (world *World) HasExtendedBlocks() bool {
+	for _, block := range world.Blocks {
+		if block > BLOCK_MAX_LEGACY {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Writes the blocks of level data, followed by the upper bits for clients supporting ExtendedBlocks
+func writeLevelBlocks(world *World, w io.Writer, extBlocks bool) error {
+	if err := writeWorldBlocks(world, w, extBlocks); err != nil {
+		return err
+	}
+
+	if extBlocks && world.HasExtendedBlocks() {
+		return writeWorldUpperBlocks(world, w)
+	}
+
 	return nil
 }
 
-func (world *World) SendWorld(player *Player) error {
+func (world *World) Volume() uint32 {
+	return uint32(world.SizeX) * uint32(world.SizeY) * uint32(world.SizeZ)
+}
+
+// Level data as sent to clients without FastMap; a gzip stream with the volume as a prefix
+func (world *World) gzipLevelData(extBlocks bool) ([]uint8, error) {
 	var b bytes.Buffer
 	gz := gzip.NewWriter(&b)
 
 	levelSize := make([]uint8, 4)
-	sizeCubed := uint32(world.SizeX) * uint32(world.SizeY) * uint32(world.SizeZ)
-	binary.BigEndian.PutUint32(levelSize, sizeCubed)
+	binary.BigEndian.PutUint32(levelSize, world.Volume())
 	if _, err := gz.Write(levelSize); err != nil {
-		return err
+		return nil, err
 	}
 
-	err := gzipWorld(world, gz)
-	if err != nil {
-		log.Println(err)
-		return err
+	if err := writeLevelBlocks(world, gz, extBlocks); err != nil {
+		return nil, err
 	}
 
 	if err := gz.Close(); err != nil {
-		log.Println(err)
-		return err
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Level data as sent to clients with FastMap; a raw DEFLATE stream without the prefix
+func (world *World) fastMapLevelData(extBlocks bool) ([]uint8, error) {
+	var b bytes.Buffer
+	fl, err := flate.NewWriter(&b, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeLevelBlocks(world, fl, extBlocks); err != nil {
+		return nil, err
+	}
+
+	if err := fl.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (world *World) SendWorld(player *Player) error {
+	fastMap := player.HasExtension(packets.EXT_FAST_MAP)
+	extBlocks := player.HasExtension(packets.EXT_EXTENDED_BLOCKS)
+
+	// Compressing the level here holds up the main loop, so it is only done until the cache is first built
+	world.updateLevelCache()
+	data, version := world.cachedLevelData(levelVariant(fastMap, extBlocks))
+	if data == nil {
+		var err error
+		if fastMap {
+			data, err = world.fastMapLevelData(extBlocks)
+		} else {
+			data, err = world.gzipLevelData(extBlocks)
+		}
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		version = world.changeCount()
 	}
 
 	levelInitPacket := packets.NewDownstreamLevelInit()
+	if fastMap {
+		levelInitPacket = packets.NewDownstreamFastMapLevelInit(int32(world.Volume()))
+	}
 	if err := player.Write(levelInitPacket); err != nil {
 		return err
 	}
 
-	data := b.Bytes()
 	chunks := [][]uint8{}
 	for i := 0; i < len(data); i += 1024 {
 		end := i + 1024
@@ -271,15 +388,37 @@ func (world *World) SendWorld(player *Player) error {
 		return err
 	}
 
+	// Catch up on the changes made since the level data was built
+	world.sendBlockChanges([]*Player{player}, world.changesSince(version))
+
 	return nil
 }
 
+// Whether clients need ExtEntityPositions to move around the whole world
+func (world *World) RequiresExtPositions() bool {
+	return world.SizeX > LEGACY_MAX_WORLD_SIZE || world.SizeY > LEGACY_MAX_WORLD_SIZE || world.SizeZ > LEGACY_MAX_WORLD_SIZE
+}
+
+// Index of a block in level data, which is ordered by Y, then Z, then X
+func (world *World) BlockIndex(x int16, y int16, z int16) int32 {
+	return (int32(y)*int32(world.SizeZ)+int32(z))*int32(world.SizeX) + int32(x)
+}
+
+// Position of the block at an index in level data
+func (world *World) BlockPosition(index int32) (int16, int16, int16) {
+	layer := int32(world.SizeX) * int32(world.SizeZ)
+	return int16(index % int32(world.SizeX)), int16(index / layer), int16(index % layer / int32(world.SizeX))
+}
+
 func (world *World) SetBlock(x int16, y int16, z int16, block Block) {
-	world.Blocks[y][z][x] = block
+	index := world.BlockIndex(x, y, z)
+	world.Blocks[index] = block
+	world.dirty.Store(true)
+	world.recordBlockChange(index, BlockChange{X: x, Y: y, Z: z, Block: block})
 }
 
 func (world *World) GetBlock(x int16, y int16, z int16) Block {
-	return world.Blocks[y][z][x]
+	return world.Blocks[world.BlockIndex(x, y, z)]
 }
 
 func (world *World) ValidBlock(x int16, y int16, z int16) bool {
@@ -290,59 +429,55 @@ func tickLava(server *ClassicServer, world *World, x int16, y int16, z int16) {
 	if world.ValidBlock(x, y-1, z) {
 		block := world.GetBlock(x, y-1, z)
 		if block == BLOCK_AIR {
-			server.SetBlock(x, y-1, z, BLOCK_LAVA_FLOWING)
+			server.SetBlock(world, x, y-1, z, BLOCK_LAVA_FLOWING)
 		} else if block == BLOCK_WATER_FLOWING || block == BLOCK_WATER_STATIONARY {
-			server.SetBlock(x, y-1, z, BLOCK_STONE)
+			server.SetBlock(world, x, y-1, z, BLOCK_STONE)
 		}
 	}
 
 	if world.ValidBlock(x+1, y, z) {
 		block := world.GetBlock(x+1, y, z)
 		if block == BLOCK_AIR {
-			server.SetBlock(x+1, y, z, BLOCK_LAVA_FLOWING)
+			server.SetBlock(world, x+1, y, z, BLOCK_LAVA_FLOWING)
 		} else if block == BLOCK_WATER_FLOWING || block == BLOCK_WATER_STATIONARY {
-			server.SetBlock(x+1, y, z, BLOCK_STONE)
+			server.SetBlock(world, x+1, y, z, BLOCK_STONE)
 		}
 	}
 
 	if world.ValidBlock(x-1, y, z) {
 		block := world.GetBlock(x-1, y, z)
 		if block == BLOCK_AIR {
-			server.SetBlock(x-1, y, z, BLOCK_LAVA_FLOWING)
+			server.SetBlock(world, x-1, y, z, BLOCK_LAVA_FLOWING)
 		} else if block == BLOCK_WATER_FLOWING || block == BLOCK_WATER_STATIONARY {
-			server.SetBlock(x-1, y, z, BLOCK_STONE)
+			server.SetBlock(world, x-1, y, z, BLOCK_STONE)
 		}
 	}
 
 	if world.ValidBlock(x, y, z+1) {
 		block := world.GetBlock(x, y, z+1)
 		if block == BLOCK_AIR {
-			server.SetBlock(x, y, z+1, BLOCK_LAVA_FLOWING)
+			server.SetBlock(world, x, y, z+1, BLOCK_LAVA_FLOWING)
 		} else if block == BLOCK_WATER_FLOWING || block == BLOCK_WATER_STATIONARY {
-			server.SetBlock(x, y, z+1, BLOCK_STONE)
+			server.SetBlock(world, x, y, z+1, BLOCK_STONE)
 		}
 	}
 
 	if world.ValidBlock(x, y, z-1) {
 		block := world.GetBlock(x, y, z-1)
 		if block == BLOCK_AIR {
-			server.SetBlock(x, y, z-1, BLOCK_LAVA_FLOWING)
+			server.SetBlock(world, x, y, z-1, BLOCK_LAVA_FLOWING)
 		} else if block == BLOCK_WATER_FLOWING || block == BLOCK_WATER_STATIONARY {
-			server.SetBlock(x, y, z-1, BLOCK_STONE)
+			server.SetBlock(world, x, y, z-1, BLOCK_STONE)
 		}
 	}
 
 }
 
 func (world *World) UpdateLava(server *ClassicServer) {
-	for y := int16(0); y < world.SizeY; y++ {
-		for z := int16(0); z < world.SizeZ; z++ {
-			for x := int16(0); x < world.SizeX; x++ {
-				block := world.Blocks[y][z][x]
-				if block == BLOCK_LAVA_FLOWING {
-					defer tickLava(server, world, x, y, z)
-				}
-			}
+	for i, block := range world.Blocks {
+		if block == BLOCK_LAVA_FLOWING {
+			x, y, z := world.BlockPosition(int32(i))
+			defer tickLava(server, world, x, y, z)
 		}
 	}
 }
@@ -351,45 +486,45 @@ func tickWater(server *ClassicServer, world *World, x int16, y int16, z int16) {
 	if world.ValidBlock(x, y-1, z) {
 		block := world.GetBlock(x, y-1, z)
 		if block == BLOCK_AIR {
-			server.SetBlock(x, y-1, z, BLOCK_WATER_FLOWING)
+			server.SetBlock(world, x, y-1, z, BLOCK_WATER_FLOWING)
 		} else if block == BLOCK_LAVA_FLOWING || block == BLOCK_LAVA_STATIONARY {
-			server.SetBlock(x, y-1, z, BLOCK_STONE)
+			server.SetBlock(world, x, y-1, z, BLOCK_STONE)
 		}
 	}
 
 	if world.ValidBlock(x+1, y, z) {
 		block := world.GetBlock(x+1, y, z)
 		if block == BLOCK_AIR {
-			server.SetBlock(x+1, y, z, BLOCK_WATER_FLOWING)
+			server.SetBlock(world, x+1, y, z, BLOCK_WATER_FLOWING)
 		} else if block == BLOCK_LAVA_FLOWING || block == BLOCK_LAVA_STATIONARY {
-			server.SetBlock(x+1, y, z, BLOCK_STONE)
+			server.SetBlock(world, x+1, y, z, BLOCK_STONE)
 		}
 	}
 
 	if world.ValidBlock(x-1, y, z) {
 		block := world.GetBlock(x-1, y, z)
 		if block == BLOCK_AIR {
-			server.SetBlock(x-1, y, z, BLOCK_WATER_FLOWING)
+			server.SetBlock(world, x-1, y, z, BLOCK_WATER_FLOWING)
 		} else if block == BLOCK_LAVA_FLOWING || block == BLOCK_LAVA_STATIONARY {
-			server.SetBlock(x-1, y, z, BLOCK_STONE)
+			server.SetBlock(world, x-1, y, z, BLOCK_STONE)
 		}
 	}
 
 	if world.ValidBlock(x, y, z+1) {
 		block := world.GetBlock(x, y, z+1)
 		if block == BLOCK_AIR {
-			server.SetBlock(x, y, z+1, BLOCK_WATER_FLOWING)
+			server.SetBlock(world, x, y, z+1, BLOCK_WATER_FLOWING)
 		} else if block == BLOCK_LAVA_FLOWING || block == BLOCK_LAVA_STATIONARY {
-			server.SetBlock(x, y, z+1, BLOCK_STONE)
+			server.SetBlock(world, x, y, z+1, BLOCK_STONE)
 		}
 	}
 
 	if world.ValidBlock(x, y, z-1) {
 		block := world.GetBlock(x, y, z-1)
 		if block == BLOCK_AIR {
-			server.SetBlock(x, y, z-1, BLOCK_WATER_FLOWING)
+			server.SetBlock(world, x, y, z-1, BLOCK_WATER_FLOWING)
 		} else if block == BLOCK_LAVA_FLOWING || block == BLOCK_LAVA_STATIONARY {
-			server.SetBlock(x, y, z-1, BLOCK_STONE)
+			server.SetBlock(world, x, y, z-1, BLOCK_STONE)
 		}
 	}
 
@@ -398,14 +533,10 @@ func tickWater(server *ClassicServer, world *World, x int16, y int16, z int16) {
 func (world *World) UpdateWater(server *ClassicServer) {
 	defer world.UpdateSponge(server)
 
-	for y := int16(0); y < world.SizeY; y++ {
-		for z := int16(0); z < world.SizeZ; z++ {
-			for x := int16(0); x < world.SizeX; x++ {
-				block := world.Blocks[y][z][x]
-				if block == BLOCK_WATER_FLOWING {
-					defer tickWater(server, world, x, y, z)
-				}
-			}
+	for i, block := range world.Blocks {
+		if block == BLOCK_WATER_FLOWING {
+			x, y, z := world.BlockPosition(int32(i))
+			defer tickWater(server, world, x, y, z)
 		}
 	}
 }
@@ -420,7 +551,7 @@ func tickSponge(server *ClassicServer, world *World, x int16, y int16, z int16)
 				if world.ValidBlock(nx, ny, nz) {
 					block := world.GetBlock(nx, ny, nz)
 					if block == BLOCK_WATER_FLOWING || block == BLOCK_WATER_STATIONARY {
-						server.SetBlock(nx, ny, nz, BLOCK_AIR)
+						server.SetBlock(world, nx, ny, nz, BLOCK_AIR)
 					}
 				}
 			}
@@ -429,14 +560,10 @@ func tickSponge(server *ClassicServer, world *World, x int16, y int16, z int16)
 }
 
 func (world *World) UpdateSponge(server *ClassicServer) {
-	for y := int16(0); y < world.SizeY; y++ {
-		for z := int16(0); z < world.SizeZ; z++ {
-			for x := int16(0); x < world.SizeX; x++ {
-				block := world.Blocks[y][z][x]
-				if block == BLOCK_SPONGE {
-					tickSponge(server, world, x, y, z)
-				}
-			}
+	for i, block := range world.Blocks {
+		if block == BLOCK_SPONGE {
+			x, y, z := world.BlockPosition(int32(i))
+			tickSponge(server, world, x, y, z)
 		}
 	}
 }
@@ -452,17 +579,17 @@ func canSandPass(block Block) bool {
 func (world *World) FallSand(server *ClassicServer, x int16, y int16, z int16) {
 	blockType := world.GetBlock(x, y, z)
 	if world.ValidBlock(x, y-1, z) && canSandPass(world.GetBlock(x, y-1, z)) {
-		server.SetBlock(x, y, z, BLOCK_AIR)
+		server.SetBlock(world, x, y, z, BLOCK_AIR)
 
 		for ny := y - 1; ny >= 0; ny-- {
 			under := world.GetBlock(x, ny, z)
 			if !canSandPass(under) && world.ValidBlock(x, ny+1, z) {
-				server.SetBlock(x, ny+1, z, blockType)
+				server.SetBlock(world, x, ny+1, z, blockType)
 				return
 			}
 		}
 
-		server.SetBlock(x, 0, z, blockType)
+		server.SetBlock(world, x, 0, z, blockType)
 	}
 }
 
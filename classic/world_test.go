@@ -0,0 +1,202 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"compress/gzip"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// World with a different block in each position, using only blocks up to maxBlock
+func newTestWorld(sizeX int16, sizeY int16, sizeZ int16, maxBlock Block) *World {
+	world := &World{
+		SizeX:      sizeX,
+		SizeY:      sizeY,
+		SizeZ:      sizeZ,
+		SpawnX:     float64(sizeX/2) + 0.5,
+		SpawnY:     float64(sizeY - 1),
+		SpawnZ:     float64(sizeZ/2) + 0.5,
+		SpawnYaw:   64,
+		SpawnPitch: 32,
+		Hacks:      DefaultHackSettings(),
+		Env:        DefaultEnvSettings(),
+		Blocks:     newBlocks(sizeX, sizeY, sizeZ),
+	}
+
+	// Filled directly, so the blocks aren't recorded as changes
+	for i := range world.Blocks {
+		world.Blocks[i] = Block(i*7) % (maxBlock + 1)
+	}
+
+	return world
+}
+
+// Fails the test unless the worlds have the same dimensions, spawn and blocks
+func assertSameWorld(t *testing.T, got *World, expected *World) {
+	t.Helper()
+
+	if got.SizeX != expected.SizeX || got.SizeY != expected.SizeY || got.SizeZ != expected.SizeZ {
+		t.Fatalf("World is %dx%dx%d, expected %dx%dx%d", got.SizeX, got.SizeY, got.SizeZ, expected.SizeX, expected.SizeY, expected.SizeZ)
+	}
+	if got.SpawnX != expected.SpawnX || got.SpawnY != expected.SpawnY || got.SpawnZ != expected.SpawnZ {
+		t.Errorf("Spawn is %v,%v,%v, expected %v,%v,%v", got.SpawnX, got.SpawnY, got.SpawnZ, expected.SpawnX, expected.SpawnY, expected.SpawnZ)
+	}
+	if got.SpawnYaw != expected.SpawnYaw || got.SpawnPitch != expected.SpawnPitch {
+		t.Errorf("Spawn rotation is %d,%d, expected %d,%d", got.SpawnYaw, got.SpawnPitch, expected.SpawnYaw, expected.SpawnPitch)
+	}
+	for y := int16(0); y < expected.SizeY; y++ {
+		for z := int16(0); z < expected.SizeZ; z++ {
+			for x := int16(0); x < expected.SizeX; x++ {
+				if block := got.GetBlock(x, y, z); block != expected.GetBlock(x, y, z) {
+					t.Fatalf("Block at %d,%d,%d is %d, expected %d", x, y, z, block, expected.GetBlock(x, y, z))
+				}
+			}
+		}
+	}
+}
+
+// Writes the data gzipped to a file in the test's temporary directory, returning its path
+func writeGzipFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	gz.Write(data)
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// Reads a gzipped file, so tests can look inside what was exported
+func readGzipFile(t *testing.T, path string) []byte {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(gz); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+// Runs the rest of the test in a temporary directory, where worlds are saved
+func chdirTemp(t *testing.T) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// Starts a background save, returning a channel receiving its result
+func saveInBackground(world *World) chan error {
+	result := make(chan error, 1)
+	world.SaveInBackground(func(snapshot *World, err error) {
+		result <- err
+	})
+	return result
+}
+
+func loadSavedWorld(t *testing.T, name string) *World {
+	t.Helper()
+
+	world, err := LoadWorld(name, 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return world
+}
+
+// The saved file holds the world as it was when the save started, however it changes meanwhile
+func TestSaveInBackground(t *testing.T) {
+	chdirTemp(t)
+
+	world := newTestWorld(64, 32, 64, BLOCK_MAX_LEGACY)
+	world.Name = "test"
+	expected := newTestWorld(64, 32, 64, BLOCK_MAX_LEGACY)
+
+	result := saveInBackground(world)
+	changes := 0
+	for saving := true; saving; changes++ {
+		select {
+		case err := <-result:
+			if err != nil {
+				t.Fatal(err)
+			}
+			saving = false
+		default:
+			i := int16(changes % (64 * 32))
+			world.SetBlock(i%64, 31-(i/64)%32, 63, BLOCK_GLASS)
+			world.SpawnX = float64(changes)
+			world.markDirty()
+		}
+	}
+
+	assertSameWorld(t, loadSavedWorld(t, "test"), expected)
+	if changes > 1 && !world.Dirty() {
+		t.Errorf("World isn't dirty after %d changes during the save", changes)
+	}
+}
+
+// A snapshot finishing after a newer one was saved, or after the world was deleted, isn't written
+func TestSaveInBackgroundOlderSnapshot(t *testing.T) {
+	chdirTemp(t)
+
+	world := newTestWorld(16, 16, 16, BLOCK_MAX_LEGACY)
+	world.Name = "test"
+
+	// Hold saves until both have started, so either may run first
+	world.saveLock.Lock()
+	first := saveInBackground(world)
+	world.SetBlock(0, 0, 0, BLOCK_GLASS)
+	expected := world.Snapshot()
+	second := saveInBackground(world)
+	world.saveLock.Unlock()
+
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-first; err != nil && err != ErrSaveSkipped {
+		t.Fatal(err)
+	}
+	assertSameWorld(t, loadSavedWorld(t, "test"), expected)
+
+	// As if a newer snapshot were saved first, or the world deleted
+	for _, saved := range []uint64{world.snapshots + 1, math.MaxUint64} {
+		world.saveLock.Lock()
+		world.savedSnapshot = saved
+		world.saveLock.Unlock()
+
+		world.SetBlock(1, 0, 0, BLOCK_GLASS)
+		if err := <-saveInBackground(world); err != ErrSaveSkipped {
+			t.Errorf("Save of a snapshot older than %d returned %v, expected ErrSaveSkipped", saved, err)
+		}
+		assertSameWorld(t, loadSavedWorld(t, "test"), expected)
+	}
+}
@@ -0,0 +1,252 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Starts world files from version 1; version 0 files are a bare gzip stream
+const WORLD_MAGIC = "GCSW"
+
+const WORLD_VERSION uint8 = 1
+
+const WORLD_FLAG_EXTENDED_BLOCKS uint8 = 0x01
+
+// Magic, version and the CRC-32 of the uncompressed payload, which is followed by the gzipped payload:
+// dimensions, spawn, flags, the lower 8 bits of each block and, with WORLD_FLAG_EXTENDED_BLOCKS, the upper bits
+const WORLD_HEADER_SIZE = len(WORLD_MAGIC) + 1 + 4
+
+var gzipMagic = []uint8{0x1F, 0x8B}
+
+func encodeWorld(world *World) ([]uint8, error) {
+	var b bytes.Buffer
+	b.WriteString(WORLD_MAGIC)
+	b.WriteByte(WORLD_VERSION)
+	// Checksum, filled in once the payload is written
+	b.Write(make([]uint8, 4))
+
+	checksum := crc32.NewIEEE()
+	gz := gzip.NewWriter(&b)
+	w := io.MultiWriter(gz, checksum)
+
+	header := make([]uint8, 0, 33)
+	header = binary.BigEndian.AppendUint16(header, uint16(world.SizeX))
+	header = binary.BigEndian.AppendUint16(header, uint16(world.SizeY))
+	header = binary.BigEndian.AppendUint16(header, uint16(world.SizeZ))
+	header = binary.BigEndian.AppendUint64(header, math.Float64bits(world.SpawnX))
+	header = binary.BigEndian.AppendUint64(header, math.Float64bits(world.SpawnY))
+	header = binary.BigEndian.AppendUint64(header, math.Float64bits(world.SpawnZ))
+	header = append(header, world.SpawnYaw, world.SpawnPitch)
+
+	extended := world.HasExtendedBlocks()
+	flags := uint8(0)
+	if extended {
+		flags |= WORLD_FLAG_EXTENDED_BLOCKS
+	}
+	header = append(header, flags)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	if err := writeWorldBlocks(world, w, true); err != nil {
+		return nil, err
+	}
+
+	if extended {
+		if err := writeWorldUpperBlocks(world, w); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	data := b.Bytes()
+	binary.BigEndian.PutUint32(data[len(WORLD_MAGIC)+1:], checksum.Sum32())
+	return data, nil
+}
+
+// Reads a world file into the world, returning the version it was saved with
+func decodeWorld(world *World, data []uint8) (uint8, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		return 0, decodeWorldPayload(world, bytes.NewReader(data), 0, 0)
+	}
+
+	if len(data) < WORLD_HEADER_SIZE || string(data[:len(WORLD_MAGIC)]) != WORLD_MAGIC {
+		return 0, errors.New("Not a world file")
+	}
+
+	version := data[len(WORLD_MAGIC)]
+	if version > WORLD_VERSION {
+		return version, fmt.Errorf("World version %d is newer than the supported version %d", version, WORLD_VERSION)
+	}
+
+	checksum := binary.BigEndian.Uint32(data[len(WORLD_MAGIC)+1:])
+	return version, decodeWorldPayload(world, bytes.NewReader(data[WORLD_HEADER_SIZE:]), version, checksum)
+}
+
+func decodeWorldPayload(world *World, r io.Reader, version uint8, checksum uint32) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	hash := crc32.NewIEEE()
+	reader := io.TeeReader(gz, hash)
+
+	readFull := func(n int) ([]uint8, error) {
+		buffer := make([]uint8, n)
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return buffer, nil
+	}
+
+	header, err := readFull(32)
+	if err != nil {
+		return err
+	}
+
+	sizeX := int16(binary.BigEndian.Uint16(header[0:]))
+	sizeY := int16(binary.BigEndian.Uint16(header[2:]))
+	sizeZ := int16(binary.BigEndian.Uint16(header[4:]))
+	if sizeX <= 0 || sizeY <= 0 || sizeZ <= 0 {
+		return fmt.Errorf("Invalid world dimensions %dx%dx%d", sizeX, sizeY, sizeZ)
+	}
+
+	flags := uint8(0)
+	if version >= 1 {
+		flagsBuffer, err := readFull(1)
+		if err != nil {
+			return err
+		}
+		flags = flagsBuffer[0]
+	}
+
+	// Blocks are read without trusting the dimensions to allocate them, so a damaged file fails before using the memory
+	volume := int(sizeX) * int(sizeY) * int(sizeZ)
+	readBlocks := func() ([]uint8, error) {
+		buffer, err := io.ReadAll(io.LimitReader(reader, int64(volume)))
+		if err != nil {
+			return nil, err
+		}
+		if len(buffer) != volume {
+			return buffer, io.ErrUnexpectedEOF
+		}
+		return buffer, nil
+	}
+
+	lower, err := readBlocks()
+	if err != nil {
+		return fmt.Errorf("Reading blocks: %w", err)
+	}
+
+	// Version 0 files have the upper bits of extended blocks if anything follows the lower bits
+	var upper []uint8
+	if version == 0 {
+		if upper, err = readBlocks(); len(upper) == 0 {
+			upper = nil
+		} else if err != nil {
+			return fmt.Errorf("Reading extended blocks: %w", err)
+		}
+	} else if flags&WORLD_FLAG_EXTENDED_BLOCKS != 0 {
+		if upper, err = readBlocks(); err != nil {
+			return fmt.Errorf("Reading extended blocks: %w", err)
+		}
+	}
+
+	// Reading to the end also verifies the gzip trailer
+	if n, err := io.ReadFull(reader, make([]uint8, 1)); n != 0 {
+		return errors.New("Unexpected data after blocks")
+	} else if err != io.EOF {
+		return err
+	}
+
+	if version >= 1 && hash.Sum32() != checksum {
+		return fmt.Errorf("Checksum mismatch, expected %08x but got %08x", checksum, hash.Sum32())
+	}
+
+	if world.SizeX != sizeX || world.SizeY != sizeY || world.SizeZ != sizeZ || world.Blocks == nil {
+		world.SizeX, world.SizeY, world.SizeZ = sizeX, sizeY, sizeZ
+		world.Blocks = newBlocks(sizeX, sizeY, sizeZ)
+	}
+
+	world.SpawnX = math.Float64frombits(binary.BigEndian.Uint64(header[6:]))
+	world.SpawnY = math.Float64frombits(binary.BigEndian.Uint64(header[14:]))
+	world.SpawnZ = math.Float64frombits(binary.BigEndian.Uint64(header[22:]))
+	world.SpawnYaw = header[30]
+	world.SpawnPitch = header[31]
+
+	for i := range world.Blocks {
+		block := Block(lower[i])
+		if upper != nil {
+			block |= Block(upper[i]) << 8
+		}
+		world.Blocks[i] = block
+	}
+
+	return nil
+}
+
+// Writes to a temporary file in the same directory, creating the directory if needed, then renames it
+// over the original once it is on disk, so a crash leaves either the old or the new file intact
+func writeFileAtomic(path string, data []uint8) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+
+	fail := func(err error) error {
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		return fail(err)
+	}
+	if err := file.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := file.Chmod(0644); err != nil {
+		return fail(err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	// Persist the rename itself; not every platform supports syncing a directory
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
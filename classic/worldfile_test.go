@@ -0,0 +1,164 @@
+package classic
+
+import (
+	"bytes"
+	. "classicserver/classic/constants"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorldFileRoundTrip(t *testing.T) {
+	for _, maxBlock := range []Block{BLOCK_MAX_LEGACY, BLOCK_MAX_EXTENDED} {
+		world := newTestWorld(20, 10, 18, maxBlock)
+		data, err := encodeWorld(world)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := &World{}
+		version, err := decodeWorld(decoded, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != WORLD_VERSION {
+			t.Errorf("Decoded version %d, expected %d", version, WORLD_VERSION)
+		}
+		assertSameWorld(t, decoded, world)
+	}
+}
+
+// Reloading a world of the same size reuses its blocks, which must all be replaced
+func TestWorldFileDecodeIntoWorld(t *testing.T) {
+	world := newTestWorld(8, 8, 8, BLOCK_MAX_EXTENDED)
+	data, err := encodeWorld(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := newTestWorld(8, 8, 8, BLOCK_MAX_LEGACY)
+	decoded.SetBlock(7, 7, 7, BLOCK_MAX_EXTENDED)
+	if _, err := decodeWorld(decoded, data); err != nil {
+		t.Fatal(err)
+	}
+	assertSameWorld(t, decoded, world)
+}
+
+// Version 0 world, a bare gzip stream without flags, followed by the upper bits of extended blocks if given
+// and any extra data
+func worldFileV0(world *World, extended bool, extra []byte) []byte {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	binary.Write(gz, binary.BigEndian, []int16{world.SizeX, world.SizeY, world.SizeZ})
+	binary.Write(gz, binary.BigEndian, []float64{world.SpawnX, world.SpawnY, world.SpawnZ})
+	gz.Write([]uint8{world.SpawnYaw, world.SpawnPitch})
+
+	shifts := []int{0}
+	if extended {
+		shifts = append(shifts, 8)
+	}
+	for _, shift := range shifts {
+		for y := int16(0); y < world.SizeY; y++ {
+			for z := int16(0); z < world.SizeZ; z++ {
+				for x := int16(0); x < world.SizeX; x++ {
+					gz.Write([]uint8{uint8(world.GetBlock(x, y, z) >> shift)})
+				}
+			}
+		}
+	}
+
+	gz.Write(extra)
+	gz.Close()
+	return b.Bytes()
+}
+
+// Version 0 has no flags, so the upper bits of extended blocks are only known by following the lower bits
+func TestWorldFileVersion0(t *testing.T) {
+	for _, extended := range []bool{false, true} {
+		maxBlock := BLOCK_MAX_LEGACY
+		if extended {
+			maxBlock = BLOCK_MAX_EXTENDED
+		}
+		world := newTestWorld(20, 10, 18, maxBlock)
+
+		decoded := &World{}
+		version, err := decodeWorld(decoded, worldFileV0(world, extended, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != 0 {
+			t.Errorf("Decoded version %d, expected 0", version)
+		}
+		assertSameWorld(t, decoded, world)
+	}
+}
+
+func TestWorldFileVersion0PartialExtended(t *testing.T) {
+	// Part of the upper bits, as an interrupted save before atomic writes could leave them
+	data := worldFileV0(newTestWorld(4, 4, 4, BLOCK_MAX_EXTENDED), false, make([]uint8, 10))
+	if _, err := decodeWorld(&World{}, data); err == nil {
+		t.Error("Version 0 world with part of the extended blocks was decoded")
+	}
+}
+
+func TestWorldFileChecksum(t *testing.T) {
+	data, err := encodeWorld(newTestWorld(8, 8, 8, BLOCK_MAX_LEGACY))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(WORLD_MAGIC)+1] ^= 0xFF
+	if _, err := decodeWorld(&World{}, data); err == nil {
+		t.Error("World with a checksum that doesn't match was decoded")
+	}
+}
+
+func TestWorldFileNewerVersion(t *testing.T) {
+	data, err := encodeWorld(newTestWorld(8, 8, 8, BLOCK_MAX_LEGACY))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(WORLD_MAGIC)] = WORLD_VERSION + 1
+	if version, err := decodeWorld(&World{}, data); err == nil || version != WORLD_VERSION+1 {
+		t.Errorf("World from a newer version was decoded as version %d with error %v", version, err)
+	}
+}
+
+func TestWorldFileOversized(t *testing.T) {
+	// Claims to be 2048x1024x2048, whose volume wraps to 0 as a uint32, and 32767x32767x32767, but holds 64 blocks
+	for _, size := range [][3]int16{{2048, 1024, 2048}, {math.MaxInt16, math.MaxInt16, math.MaxInt16}} {
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		binary.Write(gz, binary.BigEndian, size)
+		gz.Write(make([]uint8, 3*8+2+64))
+		gz.Close()
+
+		if _, err := decodeWorld(&World{}, b.Bytes()); err == nil {
+			t.Errorf("World file claiming %dx%dx%d was decoded", size[0], size[1], size[2])
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), WORLD_FILENAME)
+	for _, contents := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []uint8(contents)); err != nil {
+			t.Fatal(err)
+		}
+		if written, _ := os.ReadFile(path); string(written) != contents {
+			t.Errorf("File holds %q, expected %q", written, contents)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Directory holds %d files, expected only the world file", len(entries))
+	}
+}
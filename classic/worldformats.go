@@ -0,0 +1,65 @@
+package classic
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Largest number of blocks in an imported world, checked before allocating for it so a
+// small file claiming huge dimensions can't exhaust memory
+const MAX_IMPORT_WORLD_VOLUME = 512 * 512 * 512
+
+// Checks the dimensions read from a file, returning the world's volume
+func importWorldVolume(sizeX int, sizeY int, sizeZ int) (int, error) {
+	if sizeX <= 0 || sizeY <= 0 || sizeZ <= 0 || sizeX > math.MaxInt16 || sizeY > math.MaxInt16 || sizeZ > math.MaxInt16 {
+		return 0, fmt.Errorf("Invalid world dimensions %dx%dx%d", sizeX, sizeY, sizeZ)
+	}
+
+	volume := sizeX * sizeY * sizeZ
+	if volume > MAX_IMPORT_WORLD_VOLUME {
+		return 0, fmt.Errorf("World of %dx%dx%d is larger than the %d blocks allowed", sizeX, sizeY, sizeZ, MAX_IMPORT_WORLD_VOLUME)
+	}
+
+	return volume, nil
+}
+
+// Resolves the name given to /importworld or /exportworld to a file in the server directory,
+// defaulting to ClassicWorld when no known extension is given
+func WorldFilePath(name string) string {
+	name = filepath.Base(name)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case CLASSIC_WORLD_EXTENSION, LVL_EXTENSION, DAT_EXTENSION, MINE_EXTENSION:
+		return name
+	default:
+		return name + CLASSIC_WORLD_EXTENSION
+	}
+}
+
+// Imports a world from another server's format, chosen by the file extension
+func ImportWorldFile(path string) (*World, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case CLASSIC_WORLD_EXTENSION:
+		return ImportClassicWorld(path)
+	case LVL_EXTENSION:
+		return ImportLvl(path)
+	case DAT_EXTENSION, MINE_EXTENSION:
+		return ImportDat(path)
+	default:
+		return nil, fmt.Errorf("Unknown world format \"%s\"", filepath.Ext(path))
+	}
+}
+
+func ExportWorldFile(world *World, path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case CLASSIC_WORLD_EXTENSION:
+		return ExportClassicWorld(world, path)
+	case LVL_EXTENSION:
+		return ExportLvl(world, path)
+	case DAT_EXTENSION, MINE_EXTENSION:
+		return fmt.Errorf("Exporting to Minecraft Classic levels is unsupported")
+	default:
+		return fmt.Errorf("Unknown world format \"%s\"", filepath.Ext(path))
+	}
+}
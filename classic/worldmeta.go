@@ -0,0 +1,156 @@
+package classic
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const WORLD_META_FILENAME = "world.txt"
+
+func LoadWorldMeta(world *World) error {
+	path := world.Path(WORLD_META_FILENAME)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Creating new %s...\n", path)
+			return SaveWorldMeta(world)
+		} else {
+			return err
+		}
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(contents), "\n")
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		segments := strings.SplitN(line, "=", 2)
+		if len(segments) != 2 {
+			log.Println("Unable to interpret world setting \"", line, "\"")
+			continue
+		}
+
+		key := segments[0]
+		value := segments[1]
+
+		parseBool := func(target *bool) {
+			if parsed, err := strconv.ParseBool(value); err != nil {
+				log.Println(err)
+				log.Printf("Unable to interpret world setting \"%s\" value \"%s\"\n", key, value)
+			} else {
+				*target = parsed
+			}
+		}
+
+		switch key {
+
+		case "flying":
+			parseBool(&world.Hacks.Flying)
+
+		case "noclip":
+			parseBool(&world.Hacks.NoClip)
+
+		case "speeding":
+			parseBool(&world.Hacks.Speeding)
+
+		case "spawnControl":
+			parseBool(&world.Hacks.SpawnControl)
+
+		case "thirdPerson":
+			parseBool(&world.Hacks.ThirdPerson)
+
+		case "jumpHeight":
+			if parsed, err := strconv.ParseInt(value, 10, 16); err != nil {
+				log.Println(err)
+				log.Printf("Unable to interpret world setting \"jumpHeight\" value \"%s\"\n", value)
+			} else {
+				world.Hacks.JumpHeight = int16(parsed)
+			}
+
+		case "weather":
+			if parsed, err := ParseWeather(value); err != nil {
+				log.Println(err)
+			} else {
+				world.Env.Weather = parsed
+			}
+
+		case "lightingMode":
+			if parsed, err := ParseLightingMode(value); err != nil {
+				log.Println(err)
+			} else {
+				world.Env.LightingMode = parsed
+			}
+
+		case "lightingLocked":
+			parseBool(&world.Env.LightingLocked)
+
+		case "dayCycle":
+			parseBool(&world.Env.DayCycle)
+
+		case "dayMinutes":
+			if parsed, err := strconv.Atoi(value); err != nil || parsed <= 0 {
+				log.Printf("Unable to interpret world setting \"dayMinutes\" value \"%s\"\n", value)
+			} else {
+				world.Env.DayMinutes = parsed
+			}
+
+		case "weatherCycle":
+			parseBool(&world.Env.WeatherCycle)
+
+		case "palette":
+			if parsed, err := ParsePalette(value); err != nil {
+				log.Println(err)
+				log.Printf("Unable to interpret world setting \"palette\" value \"%s\"\n", value)
+			} else {
+				world.Palette = parsed
+			}
+
+		default:
+			for i, name := range envColorNames {
+				if key == name+"Color" {
+					if parsed, err := ParseEnvColor(value); err != nil {
+						log.Println(err)
+					} else {
+						world.Env.Colors[i] = parsed
+					}
+				}
+			}
+
+		}
+	}
+
+	return nil
+}
+
+func SaveWorldMeta(world *World) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("flying=%t\n", world.Hacks.Flying))
+	sb.WriteString(fmt.Sprintf("noclip=%t\n", world.Hacks.NoClip))
+	sb.WriteString(fmt.Sprintf("speeding=%t\n", world.Hacks.Speeding))
+	sb.WriteString(fmt.Sprintf("spawnControl=%t\n", world.Hacks.SpawnControl))
+	sb.WriteString(fmt.Sprintf("thirdPerson=%t\n", world.Hacks.ThirdPerson))
+	sb.WriteString(fmt.Sprintf("jumpHeight=%d\n", world.Hacks.JumpHeight))
+	sb.WriteString(fmt.Sprintf("weather=%s\n", weatherNames[world.Env.Weather]))
+	for i, name := range envColorNames {
+		sb.WriteString(fmt.Sprintf("%sColor=%s\n", name, world.Env.Colors[i]))
+	}
+	sb.WriteString(fmt.Sprintf("lightingMode=%s\n", lightingNames[world.Env.LightingMode]))
+	sb.WriteString(fmt.Sprintf("lightingLocked=%t\n", world.Env.LightingLocked))
+	sb.WriteString(fmt.Sprintf("dayCycle=%t\n", world.Env.DayCycle))
+	sb.WriteString(fmt.Sprintf("dayMinutes=%d\n", world.Env.DayMinutes))
+	sb.WriteString(fmt.Sprintf("weatherCycle=%t\n", world.Env.WeatherCycle))
+	sb.WriteString(fmt.Sprintf("palette=%s\n", FormatPalette(world.Palette)))
+
+	err := writeFileAtomic(world.Path(WORLD_META_FILENAME), []byte(sb.String()))
+	return err
+}
@@ -0,0 +1,510 @@
+package classic
+
+import (
+	. "classicserver/classic/constants"
+	"classicserver/classic/packets"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var validWorldName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+func ValidWorldName(name string) bool {
+	return validWorldName.MatchString(name)
+}
+
+// Names of the worlds saved in the worlds directory, sorted
+func ListWorlds() ([]string, error) {
+	entries, err := os.ReadDir(WORLDS_DIRECTORY)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !ValidWorldName(entry.Name()) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(WORLDS_DIRECTORY, entry.Name(), WORLD_FILENAME)); err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Moves the files of the single world kept by earlier versions into the named world's directory
+func migrateLegacyWorld(name string) error {
+	if _, err := os.Stat(WORLD_FILENAME); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	dir := filepath.Join(WORLDS_DIRECTORY, name)
+	if _, err := os.Stat(filepath.Join(dir, WORLD_FILENAME)); err == nil {
+		log.Printf("Both %s and %s exist; leaving %s in place\n", WORLD_FILENAME, dir, WORLD_FILENAME)
+		return nil
+	}
+
+	log.Printf("Moving %s into %s...\n", WORLD_FILENAME, dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, filename := range []string{WORLD_FILENAME, WORLD_META_FILENAME, WORLD_EXTRA_METADATA_FILENAME, BACKUPS_DIRECTORY} {
+		if err := os.Rename(filename, filepath.Join(dir, filename)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// World new players join, which is always loaded
+func (server *ClassicServer) MainWorld() *World {
+	return server.Worlds[server.Settings.MainWorld]
+}
+
+// Finds a world by name, ignoring case, and calls found with it on the main loop, right away if it is loaded.
+// A saved world that isn't loaded is loaded in the background first, once however many times it is asked for
+func (server *ClassicServer) GetWorld(name string, found func(world *World, err error)) {
+	key := strings.ToLower(name)
+	if waiting, loading := server.loadingWorlds[key]; loading {
+		server.loadingWorlds[key] = append(waiting, found)
+		return
+	}
+
+	if server.worldPending(name) {
+		found(nil, errWorldPending(name))
+		return
+	}
+
+	savedName, exists, err := server.findWorldName(name)
+	if err != nil {
+		found(nil, err)
+		return
+	} else if !exists {
+		found(nil, fmt.Errorf("Unknown world \"%s\"", name))
+		return
+	}
+
+	if world, loaded := server.Worlds[savedName]; loaded {
+		found(world, nil)
+		return
+	}
+
+	server.loadingWorlds[key] = []func(world *World, err error){found}
+	go func() {
+		world, err := server.readWorld(savedName)
+		server.runOnMainLoop(func() {
+			waiting := server.loadingWorlds[key]
+			delete(server.loadingWorlds, key)
+			if err == nil {
+				world.lastActive = time.Now()
+				server.Worlds[savedName] = world
+			}
+
+			for _, found := range waiting {
+				found(world, err)
+			}
+		})
+	}()
+}
+
+// Loads the named world into the registry, generating it with the default size if it doesn't exist
+func (server *ClassicServer) loadWorld(name string) (*World, error) {
+	world, err := server.readWorld(name)
+	if err != nil {
+		return nil, err
+	}
+
+	world.lastActive = time.Now()
+	server.Worlds[name] = world
+	return world, nil
+}
+
+// Loads the named world without registering it, generating it with the default size if it doesn't exist
+func (server *ClassicServer) readWorld(name string) (*World, error) {
+	world, err := LoadWorld(name, server.Settings.WorldX, server.Settings.WorldY, server.Settings.WorldZ)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := ListBackups(world)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) > 0 {
+		world.lastBackup = backups[0].Time
+	}
+
+	return world, nil
+}
+
+func (server *ClassicServer) PlayersIn(world *World) []*Player {
+	players := []*Player{}
+	for _, player := range server.Players {
+		if player.World == world {
+			players = append(players, player)
+		}
+	}
+
+	return players
+}
+
+// Sends the player to the world's spawn
+func (server *ClassicServer) ChangeWorld(player *Player, world *World) error {
+	return server.moveToWorld(player, world, FPShort(world.SpawnX), FPShort(world.SpawnY), FPShort(world.SpawnZ), world.SpawnYaw, world.SpawnPitch)
+}
+
+// Moves the player between worlds, resending the MOTD for the world's hacks, then the world and its settings
+func (server *ClassicServer) moveToWorld(player *Player, world *World, x FPShort, y FPShort, z FPShort, yaw uint8, pitch uint8) error {
+	if world.RequiresExtPositions() && !player.HasExtension(packets.EXT_ENTITY_POSITIONS) {
+		return errors.New("This world requires a client supporting ExtEntityPositions")
+	}
+
+	now := time.Now()
+	if old := player.World; old != nil {
+		despawnPacket := packets.NewDownstreamDespawnPlayer(player.Id)
+		for _, other := range server.PlayersIn(old) {
+			if other.Id != player.Id {
+				other.Write(despawnPacket)
+			}
+		}
+		old.lastActive = now
+	}
+
+	player.World = world
+	world.lastActive = now
+
+	serverIdentificationPacket := packets.NewDownstreamServerIdentification(
+		server.Settings.Name,
+		server.MOTD(world),
+		player.Mode,
+	)
+	if err := player.Write(serverIdentificationPacket); err != nil {
+		return err
+	}
+
+	if err := world.SendWorld(player); err != nil {
+		return err
+	}
+	player.SendWorldSettings(world)
+
+	player.X = x
+	player.Y = y
+	player.Z = z
+	player.Yaw = yaw
+	player.Pitch = pitch
+	server.spawnPlayer(player)
+
+	return nil
+}
+
+// Saves a snapshot of the world in the background if it has changed, backing it up if one is due.
+// saved, if it isn't nil, runs on the main loop once the save is over, whether or not it succeeded
+func (server *ClassicServer) saveWorldInBackground(world *World, saved func()) {
+	if world.ReadOnly || !world.Dirty() {
+		if saved != nil {
+			saved()
+		}
+		return
+	}
+
+	backup := world.backupDue(server.Settings.BackupMinutes)
+	world.SaveInBackground(func(snapshot *World, err error) {
+		if err == nil && backup {
+			server.backupWorld(snapshot)
+		}
+		if err != nil && err != ErrSaveSkipped {
+			log.Println(err)
+		}
+		if saved != nil {
+			server.runOnMainLoop(saved)
+		}
+	})
+}
+
+func NewWorldUnloadTicker() *time.Ticker {
+	return time.NewTicker(time.Minute)
+}
+
+// Saves and unloads worlds other than the main world that have been empty for the worldUnloadMinutes setting
+func (server *ClassicServer) UnloadIdleWorlds() {
+	if server.Settings.WorldUnloadMinutes <= 0 {
+		return
+	}
+
+	idle := time.Duration(server.Settings.WorldUnloadMinutes) * time.Minute
+	for name, world := range server.Worlds {
+		if world == server.MainWorld() || len(server.PlayersIn(world)) > 0 || time.Since(world.lastActive) < idle {
+			continue
+		}
+
+		if world.unloading {
+			continue
+		}
+
+		// Keep the world loaded until it is saved, so going there meanwhile doesn't load the old file
+		world.unloading = true
+		name, world := name, world
+		server.saveWorldInBackground(world, func() {
+			world.unloading = false
+
+			// Keep it if it was visited or changed while saving
+			if server.Worlds[name] != world || len(server.PlayersIn(world)) > 0 || world.Dirty() {
+				return
+			}
+
+			delete(server.Worlds, name)
+			log.Printf("Unloaded idle world %s\n", name)
+		})
+	}
+}
+
+// Largest number of blocks in a world created in game, which is held in memory while it is generated
+const MAX_NEW_WORLD_VOLUME = 512 * 256 * 512
+
+// Name of the loaded or saved world, ignoring case, and whether there is one
+func (server *ClassicServer) findWorldName(name string) (string, bool, error) {
+	for loadedName := range server.Worlds {
+		if strings.EqualFold(loadedName, name) {
+			return loadedName, true, nil
+		}
+	}
+
+	names, err := ListWorlds()
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, saved := range names {
+		if strings.EqualFold(saved, name) {
+			return saved, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// Whether a world by the name is loaded, saved or being created, ignoring case
+func (server *ClassicServer) worldExists(name string) (bool, error) {
+	if server.worldPending(name) {
+		return true, nil
+	}
+
+	_, exists, err := server.findWorldName(name)
+	return exists, err
+}
+
+// Whether a world by the name is being created, deleted, renamed or loaded in the background
+func (server *ClassicServer) worldPending(name string) bool {
+	key := strings.ToLower(name)
+	_, loading := server.loadingWorlds[key]
+	return server.pendingWorlds[key] || loading
+}
+
+func errWorldPending(name string) error {
+	return fmt.Errorf("World \"%s\" is being changed; try again shortly", name)
+}
+
+// Generates and saves a new world in the background, then loads it and calls created on the main loop.
+// The name is reserved meanwhile, so it can't be taken by another world
+func (server *ClassicServer) CreateWorld(name string, sizeX int16, sizeY int16, sizeZ int16, generator WorldGenerator, seed int64, created func(world *World, err error)) error {
+	if !ValidWorldName(name) {
+		return fmt.Errorf("Invalid world name \"%s\" (Letters, numbers, _ and -)", name)
+	}
+
+	if exists, err := server.worldExists(name); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("World \"%s\" already exists", name)
+	}
+
+	if sizeX <= 0 || sizeY <= 0 || sizeZ <= 0 {
+		return fmt.Errorf("Invalid world dimensions %dx%dx%d", sizeX, sizeY, sizeZ)
+	}
+	if int(sizeX)*int(sizeY)*int(sizeZ) > MAX_NEW_WORLD_VOLUME {
+		return fmt.Errorf("Worlds can have at most %d blocks", MAX_NEW_WORLD_VOLUME)
+	}
+
+	key := strings.ToLower(name)
+	server.pendingWorlds[key] = true
+
+	go func() {
+		world := NewWorld(sizeX, sizeY, sizeZ)
+		world.Name = name
+		generator(world, seed)
+		err := SaveWorld(world)
+
+		server.runOnMainLoop(func() {
+			delete(server.pendingWorlds, key)
+			if err != nil {
+				created(nil, err)
+				return
+			}
+
+			for loadedName := range server.Worlds {
+				if strings.EqualFold(loadedName, name) {
+					created(nil, fmt.Errorf("World \"%s\" already exists", loadedName))
+					return
+				}
+			}
+
+			world.lastActive = time.Now()
+			server.Worlds[name] = world
+			created(world, nil)
+		})
+	}()
+
+	return nil
+}
+
+// Sends players in the named world, or viewing its backups, to the main world
+func (server *ClassicServer) evacuateWorld(name string) {
+	main := server.MainWorld()
+	for _, player := range server.Players {
+		if player.World != nil && player.World.Name == name {
+			if err := server.ChangeWorld(player, main); err != nil {
+				player.Kick(err.Error())
+				continue
+			}
+			player.SendMessage("%sWorld %s has been removed; you have been sent to %s", COLOR_YELLOW, name, main.Name)
+		}
+	}
+}
+
+// Deletes a world other than the main world, with its backups, sending anyone in it to the main world.
+// The directory is removed in the background, without loading the world, then deleted is called on the main loop
+func (server *ClassicServer) DeleteWorld(name string, deleted func(err error)) error {
+	if server.worldPending(name) {
+		return errWorldPending(name)
+	}
+
+	found, exists, err := server.findWorldName(name)
+	if err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("Unknown world \"%s\"", name)
+	}
+
+	if strings.EqualFold(found, server.Settings.MainWorld) {
+		return errors.New("The main world can't be deleted")
+	}
+
+	world := server.Worlds[found]
+	server.evacuateWorld(found)
+	delete(server.Worlds, found)
+
+	key := strings.ToLower(found)
+	server.pendingWorlds[key] = true
+
+	go func() {
+		// Wait for any save in progress, and skip those yet to start, so the directory isn't recreated
+		if world != nil {
+			world.saveLock.Lock()
+			defer world.saveLock.Unlock()
+			world.savedSnapshot = math.MaxUint64
+		}
+
+		err := os.RemoveAll(filepath.Join(WORLDS_DIRECTORY, found))
+		server.runOnMainLoop(func() {
+			delete(server.pendingWorlds, key)
+			deleted(err)
+		})
+	}()
+
+	return nil
+}
+
+// Renames a world other than the main world, along with its directory. The directory is renamed in the
+// background, without loading the world, then renamed is called on the main loop
+func (server *ClassicServer) RenameWorld(name string, newName string, renamed func(err error)) error {
+	if !ValidWorldName(newName) {
+		return fmt.Errorf("Invalid world name \"%s\" (Letters, numbers, _ and -)", newName)
+	}
+
+	if server.worldPending(name) {
+		return errWorldPending(name)
+	}
+
+	oldName, exists, err := server.findWorldName(name)
+	if err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("Unknown world \"%s\"", name)
+	}
+
+	if strings.EqualFold(oldName, server.Settings.MainWorld) {
+		return errors.New("The main world can't be renamed; change mainWorld in settings.txt instead")
+	}
+
+	// Allow changing only the case of the name
+	if !strings.EqualFold(oldName, newName) {
+		if exists, err := server.worldExists(newName); err != nil {
+			return err
+		} else if exists {
+			return fmt.Errorf("World \"%s\" already exists", newName)
+		}
+	}
+
+	world := server.Worlds[oldName]
+	oldKey, newKey := strings.ToLower(oldName), strings.ToLower(newName)
+	server.pendingWorlds[oldKey] = true
+	server.pendingWorlds[newKey] = true
+
+	go func() {
+		// Saves in progress finish in the old directory, and later ones wait until the name has changed
+		if world != nil {
+			world.saveLock.Lock()
+			defer world.saveLock.Unlock()
+		}
+
+		err := os.Rename(filepath.Join(WORLDS_DIRECTORY, oldName), filepath.Join(WORLDS_DIRECTORY, newName))
+
+		// Held until the name has changed on the main loop, where only ReplaceWorld waits for saveLock,
+		// and it isn't used on worlds being renamed
+		applied := make(chan struct{})
+		server.runOnMainLoop(func() {
+			defer close(applied)
+			delete(server.pendingWorlds, oldKey)
+			delete(server.pendingWorlds, newKey)
+			if err != nil {
+				renamed(err)
+				return
+			}
+
+			if world != nil && server.Worlds[oldName] == world {
+				delete(server.Worlds, oldName)
+				world.Name = newName
+				server.Worlds[newName] = world
+			}
+
+			// Read-only copies, such as backups being viewed, follow the world
+			for _, player := range server.Players {
+				if player.World != nil && player.World.Name == oldName {
+					player.World.Name = newName
+				}
+			}
+
+			renamed(nil)
+		})
+		<-applied
+	}()
+
+	return nil
+}
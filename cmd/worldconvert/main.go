@@ -0,0 +1,67 @@
+// Converts between a world in the current server directory and other servers' formats,
+// chosen by the file extension: ClassicWorld (.cw), MCGalaxy/MCSharp (.lvl) or, for import only,
+// Minecraft Classic (.dat/.mine). The world defaults to main.
+//
+//	worldconvert import <file> [world]   replaces the world and its metadata with the file
+//	worldconvert export <file> [world]   writes the world and its metadata to the file
+package main
+
+import (
+	"classicserver/classic"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: worldconvert <import|export> <file.cw|file.lvl|file.dat> [world]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) != 3 && len(os.Args) != 4 {
+		usage()
+	}
+
+	path := os.Args[2]
+	name := "main"
+	if len(os.Args) == 4 {
+		name = os.Args[3]
+	}
+	if !classic.ValidWorldName(name) {
+		log.Fatalf("Invalid world name \"%s\"\n", name)
+	}
+
+	switch os.Args[1] {
+
+	case "import":
+		world, err := classic.ImportWorldFile(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		world.Name = name
+		if err := classic.SaveWorld(world); err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Imported %s into %s (%dx%dx%d)\n", path, name, world.SizeX, world.SizeY, world.SizeZ)
+
+	case "export":
+		if _, err := os.Stat(filepath.Join(classic.WORLDS_DIRECTORY, name, classic.WORLD_FILENAME)); err != nil {
+			log.Fatalln(err)
+		}
+		// Dimensions are read from the file; these only size the world before loading
+		world, err := classic.LoadWorld(name, 16, 16, 16)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := classic.ExportWorldFile(world, path); err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Exported %s (%dx%dx%d)\n", path, world.SizeX, world.SizeY, world.SizeZ)
+
+	default:
+		usage()
+
+	}
+}